@@ -0,0 +1,327 @@
+// Command estatectl — административные операции без HTTP: пользователи и роли,
+// миграции, демо-данные, импорт/экспорт объектов и очистка корзины.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"example-app/pkg/estate"
+	"example-app/pkg/migrate"
+	"example-app/pkg/store"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+const usage = `Использование: estatectl <команда> [флаги]
+
+Команды:
+  create-user     -name -email -password [-role admin|agent|user]
+  set-role        -email -role admin|agent|user
+  reset-password  -email -password
+  migrate         up | down [-steps N] | status
+  seed            заполнить базу демо-данными
+  import          -file listings.csv|.xlsx -owner email [-mapping JSON] [-dry-run]
+  export          [-resource properties|purchases|sales] [-format csv|xlsx|jsonl] [-out file] [фильтр=значение ...]
+  purge-trash     [-days N]
+
+Подключение берётся из CONNECT_SQL (переменная окружения или .env).
+`
+
+// cliIP — что пишется в audit_log.ip для изменений из estatectl.
+const cliIP = "estatectl"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	// .env необязателен: в CI переменные обычно заданы окружением
+	godotenv.Load()
+
+	commands := map[string]func([]string) error{
+		"create-user":    createUser,
+		"set-role":       setRole,
+		"reset-password": resetPassword,
+		"migrate":        runMigrate,
+		"seed":           seed,
+		"import":         importListings,
+		"export":         exportListings,
+		"purge-trash":    purgeTrash,
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "estatectl:", err)
+		os.Exit(1)
+	}
+}
+
+func connect() (*sqlx.DB, error) {
+	return estate.Connect()
+}
+
+// cliContext — контекст, от имени которого изменения попадают в журнал.
+func cliContext() context.Context {
+	return estate.WithActor(context.Background(), estate.Actor{IP: cliIP})
+}
+
+func parseRole(s string) (int, error) {
+	switch s {
+	case "admin", "1":
+		return store.RoleAdmin, nil
+	case "agent", "2":
+		return store.RoleAgent, nil
+	case "user", "3":
+		return store.RoleUser, nil
+	}
+	return 0, fmt.Errorf("unknown role: %s", s)
+}
+
+func createUser(args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ExitOnError)
+	name := fs.String("name", "", "имя пользователя")
+	email := fs.String("email", "", "email")
+	password := fs.String("password", "", "пароль")
+	role := fs.String("role", "user", "роль: admin, agent или user")
+	fs.Parse(args)
+	if *name == "" || *email == "" || *password == "" {
+		return fmt.Errorf("-name, -email and -password are required")
+	}
+	roleID, err := parseRole(*role)
+	if err != nil {
+		return err
+	}
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	id, err := store.NewStoreDB(db).CreateUser(*name, *email, *password, roleID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Пользователь %s создан (id %d, роль %s)\n", *email, id, *role)
+	return nil
+}
+
+func setRole(args []string) error {
+	fs := flag.NewFlagSet("set-role", flag.ExitOnError)
+	email := fs.String("email", "", "email")
+	role := fs.String("role", "", "роль: admin, agent или user")
+	fs.Parse(args)
+	roleID, err := parseRole(*role)
+	if err != nil || *email == "" {
+		return fmt.Errorf("-email and valid -role are required")
+	}
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := store.NewStoreDB(db).SetRole(*email, roleID); err != nil {
+		return err
+	}
+	fmt.Printf("Роль пользователя %s: %s\n", *email, *role)
+	return nil
+}
+
+func resetPassword(args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	email := fs.String("email", "", "email")
+	password := fs.String("password", "", "новый пароль")
+	fs.Parse(args)
+	if *email == "" || *password == "" {
+		return fmt.Errorf("-email and -password are required")
+	}
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := store.NewStoreDB(db).ResetPassword(*email, *password); err != nil {
+		return err
+	}
+	fmt.Printf("Пароль пользователя %s изменён\n", *email)
+	return nil
+}
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: expected up, down or status")
+	}
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := fs.Int("steps", 1, "сколько миграций откатить")
+	fs.Parse(args[1:])
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		n, err := migrate.Up(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Применено миграций: %d\n", n)
+	case "down":
+		n, err := migrate.Down(db, *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Откачено миграций: %d\n", n)
+	case "status":
+		list, err := migrate.List(db)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			state := "не применена"
+			if m.AppliedAt != nil {
+				state = m.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-24s %s\n", m.Version, m.Name, state)
+		}
+	default:
+		return fmt.Errorf("migrate: unknown action %s", args[0])
+	}
+	return nil
+}
+
+func importListings(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "CSV или XLSX с объектами")
+	owner := fs.String("owner", "", "email владельца объектов")
+	format := fs.String("format", "", "csv или xlsx (по умолчанию — по расширению)")
+	mapping := fs.String("mapping", "", `сопоставление колонок, например {"Адрес":"address"}`)
+	dryRun := fs.Bool("dry-run", false, "только проверить файл")
+	fs.Parse(args)
+	if *file == "" || *owner == "" {
+		return fmt.Errorf("-file and -owner are required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	rows, err := estate.ParseRows(data, *format)
+	if err != nil {
+		return err
+	}
+	m := map[string]string{}
+	if *mapping != "" {
+		if err := json.Unmarshal([]byte(*mapping), &m); err != nil {
+			return fmt.Errorf("invalid -mapping: %v", err)
+		}
+	}
+	items, report, err := estate.DecodeRows[estate.Property](rows, m)
+	if err != nil {
+		return err
+	}
+	for _, e := range report.Errors {
+		fmt.Printf("строка %d: %s\n", e.Row, e.Error)
+	}
+	if *dryRun {
+		fmt.Printf("Проверено строк: %d, корректных: %d\n", report.Total, len(items))
+		return nil
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ownerID, err := store.NewStoreDB(db).UserIDByEmail(*owner)
+	if err != nil {
+		return fmt.Errorf("owner %s: %v", *owner, err)
+	}
+	ctx := estate.WithAuditAction(cliContext(), estate.AuditImport)
+	inserted, err := estate.NewPostgresRepositories(db).Properties.Insert(ctx, ownerID, items...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Импортировано %d из %d строк\n", len(inserted), report.Total)
+	return nil
+}
+
+func exportListings(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	resource := fs.String("resource", "properties", "properties, purchases или sales")
+	format := fs.String("format", "csv", "csv, xlsx или jsonl")
+	out := fs.String("out", "", "файл (по умолчанию stdout)")
+	fs.Parse(args)
+
+	// оставшиеся аргументы — фильтры списка в виде имя=значение
+	values := url.Values{}
+	for _, a := range fs.Args() {
+		k, v, ok := strings.Cut(a, "=")
+		if !ok {
+			return fmt.Errorf("bad filter %q, expected name=value", a)
+		}
+		values.Set(k, v)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	repos := estate.NewPostgresRepositories(db)
+
+	ctx := context.Background()
+	switch *resource {
+	case "properties":
+		return estate.ExportTo(ctx, repos.Properties, w, *format, values)
+	case "purchases":
+		return estate.ExportTo(ctx, repos.Purchases, w, *format, values)
+	case "sales":
+		return estate.ExportTo(ctx, repos.Sales, w, *format, values)
+	}
+	return fmt.Errorf("unknown resource: %s", *resource)
+}
+
+func purgeTrash(args []string) error {
+	fs := flag.NewFlagSet("purge-trash", flag.ExitOnError)
+	days := fs.Int("days", 0, "срок хранения в днях (по умолчанию TRASH_RETENTION_DAYS или 30)")
+	fs.Parse(args)
+	retention := estate.TrashRetention()
+	if *days > 0 {
+		retention = time.Duration(*days) * 24 * time.Hour
+	}
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	repos := estate.NewPostgresRepositories(db)
+	n, err := estate.PurgeTrash(context.Background(), retention, repos.TrashPurgers()...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Удалено из корзины: %d (старше %d дн.)\n", n, int(retention.Hours()/24))
+	return nil
+}
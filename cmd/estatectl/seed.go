@@ -0,0 +1,115 @@
+package main
+
+import (
+	"example-app/pkg/estate"
+	"example-app/pkg/store"
+	"fmt"
+	"time"
+)
+
+// демо-пароль одинаковый у всех демо-пользователей — только для локальной разработки
+const demoPassword = "password"
+
+var demoUsers = []struct {
+	name, email string
+	role        int
+}{
+	{"Администратор", "admin@example.com", store.RoleAdmin},
+	{"Агент", "agent@example.com", store.RoleAgent},
+	{"Покупатель", "user@example.com", store.RoleUser},
+}
+
+// usd — сумма в целых долларах (Amount хранит центы).
+func usd(v int64) estate.Amount {
+	return estate.Amount(v * 100)
+}
+
+// demoRates — курсы к базовой валюте (USD) на момент заполнения.
+var demoRates = map[estate.Currency]string{
+	"TJS": "0.09150000",
+	"EUR": "1.08000000",
+}
+
+func demoProperties() []estate.Property {
+	coord := func(v float64) *float64 { return &v }
+	return []estate.Property{
+		{Address: "г. Душанбе, пр. Рудаки 10", Type: "Apartment", Price: usd(85000), Latitude: coord(38.5598), Longitude: coord(68.7870)},
+		{Address: "г. Душанбе, ул. Шотемур 25", Type: "House", Price: usd(240000), Latitude: coord(38.5731), Longitude: coord(68.7864)},
+		{Address: "г. Душанбе, ул. Айни 48", Type: "Studio", Price: usd(42000), Latitude: coord(38.5505), Longitude: coord(68.8011)},
+		{Address: "г. Худжанд, ул. Ленина 3", Type: "Office", Price: usd(130000), Latitude: coord(40.2826), Longitude: coord(69.6222)},
+	}
+}
+
+func seed(args []string) error {
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	users := store.NewStoreDB(db)
+
+	ids := map[int]int{}
+	for _, u := range demoUsers {
+		id, err := users.UserIDByEmail(u.email)
+		if err != nil {
+			if id, err = users.CreateUser(u.name, u.email, demoPassword, u.role); err != nil {
+				return fmt.Errorf("create %s: %v", u.email, err)
+			}
+			fmt.Printf("Создан пользователь %s (пароль %q)\n", u.email, demoPassword)
+		}
+		ids[u.role] = id
+	}
+
+	repos := estate.NewPostgresRepositories(db)
+	ctx := cliContext()
+	_, count, err := repos.Properties.List(ctx, &estate.ListQuery{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		fmt.Println("Объекты уже есть — демо-объекты не добавлены")
+		return nil
+	}
+
+	agentID := ids[store.RoleAgent]
+	props, err := repos.Properties.Insert(ctx, agentID, demoProperties()...)
+	if err != nil {
+		return err
+	}
+	lifecycle := estate.NewLifecycle(repos)
+	for _, p := range props {
+		if _, err := lifecycle.Fire(ctx, p.ID, estate.EventPublish, store.RoleAdmin); err != nil {
+			return err
+		}
+	}
+	purchases := []estate.Purchase{
+		{PropertyID: props[0].ID, SellerID: ids[store.RoleUser], PurchaseDate: time.Now(), InitialPrice: usd(80000)},
+	}
+	if _, err := repos.Purchases.Insert(ctx, agentID, purchases...); err != nil {
+		return err
+	}
+	sales := []estate.Sale{
+		{PropertyID: props[2].ID, BuyerID: ids[store.RoleUser], SaleDate: time.Now(), FinalPrice: usd(41000)},
+	}
+	plan := estate.CommissionPlan{Name: "Стандартный", Kind: estate.PlanFlat, Rate: 3}
+	if _, err := repos.CommissionPlans.Insert(ctx, ids[store.RoleAdmin], plan); err != nil {
+		return err
+	}
+	for currency, s := range demoRates {
+		rate, err := estate.ParseRate(s)
+		if err != nil {
+			return err
+		}
+		if _, err := repos.Rates.Insert(ctx, ids[store.RoleAdmin], estate.ExchangeRate{Currency: currency, Rate: rate}); err != nil {
+			return err
+		}
+	}
+	saleService := estate.NewSaleService(repos)
+	for _, s := range sales {
+		if _, err := saleService.Register(ctx, s, agentID); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Добавлено объектов: %d, покупок: %d, продаж: %d\n", len(props), len(purchases), len(sales))
+	return nil
+}
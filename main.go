@@ -2,16 +2,18 @@ package main
 
 import (
 	"example-app/pkg/estate"
+	"example-app/pkg/migrate"
+	"example-app/pkg/storage"
 	"example-app/pkg/store"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth"
-	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -32,27 +34,84 @@ func initEnv() {
 
 func main() {
 	initEnv()
-	err := estate.InitDB()
+	db, err := estate.Connect()
 	if err != nil {
 		log.Fatalln(err)
 	}
-	db, err := sqlx.Connect("postgres", os.Getenv("CONNECT_SQL"))
-	if err != nil {
-		log.Fatal(err)
-	}
 	log.Println("Успешно подключено к базе данных.")
 	defer db.Close()
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		applied, err := migrate.Up(db)
+		if err != nil {
+			log.Fatalln("Ошибка миграции:", err)
+		}
+		log.Printf("Применено миграций: %d", applied)
+	}
+
+	files, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	repos := estate.NewPostgresRepositories(db)
+	r := newRouter(repos, store.NewStoreDB(db), files)
+	go estate.RunTrashPurger(time.Hour, estate.TrashRetention(), repos.TrashPurgers()...)
+	go estate.RunExpirers(time.Minute, estate.NewReservationService(repos), estate.NewOfferService(repos))
+	go estate.RunAlerts(time.Minute, estate.NewAlertService(repos, estate.LogNotifier{}))
+	fmt.Println("Server started on :3000")
+	http.ListenAndServe(":3000", r)
+}
+
+// newRouter собирает API поверх переданных хранилищ; с estate.NewMemoryRepositories
+// и estate.NewMemoryUsers его можно поднять без базы данных.
+func newRouter(repos *estate.Repositories, auth store.Users, files storage.Storage) http.Handler {
+	properties := estate.NewHandler(repos.Properties)
+	photoService := estate.NewPhotoService(repos, files)
+	favoriteService := estate.NewFavoriteService(repos)
+	savedSearches := estate.NewHandler(repos.SavedSearches)
+	notifications := estate.NewHandler(repos.Notifications)
+	alertService := estate.NewAlertService(repos, estate.LogNotifier{})
+	purchases := estate.NewHandler(repos.Purchases)
+	sales := estate.NewHandler(repos.Sales)
+	saleService := estate.NewSaleService(repos)
+	lifecycle := estate.NewLifecycle(repos)
+	reservations := estate.NewHandler(repos.Reservations)
+	reservationService := estate.NewReservationService(repos)
+	viewings := estate.NewHandler(repos.Viewings)
+	viewingService := estate.NewViewingService(repos)
+	offers := estate.NewHandler(repos.Offers)
+	offerService := estate.NewOfferService(repos)
+	commissions := estate.NewHandler(repos.Commissions)
+	commissionPlans := estate.NewHandler(repos.CommissionPlans)
+	commissionService := estate.NewCommissionService(repos)
+	templates := estate.NewHandler(repos.Templates)
+	documents := estate.NewHandler(repos.Documents)
+	documentService := estate.NewDocumentService(repos)
+	dealDocuments := estate.NewDealDocumentService(repos, files)
+	rates := estate.NewHandler(repos.Rates)
+	users := estate.NewHandler(repos.Users)
+	audit := estate.NewHandler(repos.Audit)
+
+	access := estate.NewAccess(repos.Users)
+	estate.RegisterOwner(access, repos.Properties)
+	estate.RegisterOwner(access, repos.Purchases)
+	estate.RegisterOwner(access, repos.Sales)
+	estate.RegisterOwner(access, repos.Reservations)
+	estate.RegisterOwner(access, repos.Viewings)
+
 	r := chi.NewRouter()
 
 	r.Use(
+		middleware.RealIP,
 		middleware.Logger,
 		middleware.Recoverer,
 		jwtauth.Verifier(tokenAuth),
 		func(next http.Handler) http.Handler {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+				w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Checksum-SHA256")
 				if r.Method == "OPTIONS" {
 					w.WriteHeader(http.StatusOK)
 					return
@@ -61,57 +120,200 @@ func main() {
 			})
 		},
 	)
-	auth := store.NewStoreDB(db)
 	login := store.NewStore(auth, tokenAuth)
-	r.Post("/register", auth.Register)
+	r.Post("/register", login.Register)
 	r.Post("/login", login.Login)
+	// подписанные ссылки из GET /documents/{id}/link работают без токена
+	r.Get("/documents/files/{versionID}", dealDocuments.Download)
 	r.Group(func(r chi.Router) {
 		r.Use(jwtauth.Authenticator)
+		r.Use(estate.Currencies(repos.Rates))
+		r.Get("/exchange-rates", rates.Read)
+		r.Get("/favorites", favoriteService.Mine)
+		r.Get("/notifications", notifications.GetMyData)
+		r.Route("/saved-searches", func(r chi.Router) {
+			r.Get("/", savedSearches.GetMyData)
+			r.Post("/", alertService.Create)
+			r.Get("/{id}", alertService.GetHandler)
+			r.Put("/{id}", alertService.UpdateHandler)
+			r.Delete("/{id}", alertService.DeleteHandler)
+		})
 		r.Route("/properties", func(r chi.Router) {
 			r.Group(func(r chi.Router) {
-				r.Post("/", estate.Create[estate.Property])
-				r.Get("/", estate.Read[estate.Property])
-				r.Get("/my", estate.GetMyData[estate.Property])
-				r.Get("/{id}", estate.GetByID[estate.Property])
-				r.Put("/{id}", estate.Update[estate.Property])
-				r.Delete("/{id}", estate.Delete[estate.Property])
+				r.Post("/", properties.Create)
+				r.Get("/", properties.Read)
+				r.Get("/my", properties.GetMyData)
+				r.Get("/search", estate.SearchProperties(repos.Search))
+				r.Get("/geojson", properties.ReadGeoJSON)
+				r.Post("/import", properties.Import)
+				r.Get("/{id}", properties.GetByID)
+				r.Put("/{id}", properties.Update)
+				r.Patch("/{id}", properties.Patch)
+				r.Delete("/{id}", properties.Delete)
+				r.Post("/{id}/favorite", favoriteService.AddHandler)
+				r.Delete("/{id}/favorite", favoriteService.RemoveHandler)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(access.RequireAdminOrAgent)
+				r.Get("/{id}/history", estate.History[estate.Property](repos.Audit))
+				r.Get("/{id}/transitions", lifecycle.History)
+				r.Post("/{id}/transitions/{event}", lifecycle.Transition)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(access.LoadRole)
+				r.Get("/{id}/photos", photoService.List)
+				r.Post("/{id}/photos", photoService.UploadHandler)
+				r.Put("/{id}/photos/order", photoService.OrderHandler)
+				r.Get("/{id}/photos/{photoID}/{size}", photoService.File)
+				r.Post("/{id}/photos/{photoID}/cover", photoService.CoverHandler)
+				r.Delete("/{id}/photos/{photoID}", photoService.DeleteHandler)
+			})
+		})
+		r.Route("/reservations", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(access.RequireAdminOrAgent)
+				r.Get("/", reservations.Read)
+				r.Get("/my", reservations.GetMyData)
+				r.Post("/", reservationService.Create)
+				r.Get("/{id}", reservations.GetByID)
+				r.Post("/{id}/extend", reservationService.ExtendHandler)
+				r.Post("/{id}/release", reservationService.ReleaseHandler)
+			})
+		})
+		r.Route("/viewings", func(r chi.Router) {
+			r.Post("/", viewingService.Create)
+			r.Get("/upcoming", viewingService.Upcoming)
+			r.Post("/{id}/cancel", viewingService.CancelHandler())
+			r.Group(func(r chi.Router) {
+				r.Use(access.RequireAdminOrAgent)
+				r.Get("/", viewings.Read)
+				r.Get("/my", viewings.GetMyData)
+				r.Get("/calendar.ics", viewingService.Calendar)
+				r.Get("/{id}", viewings.GetByID)
+				r.Post("/{id}/confirm", viewingService.ConfirmHandler())
+				r.Post("/{id}/decline", viewingService.DeclineHandler())
+			})
+		})
+		r.Route("/offers", func(r chi.Router) {
+			r.Post("/", offerService.Create)
+			r.Get("/my", offerService.Mine)
+			r.Group(func(r chi.Router) {
+				r.Use(access.LoadRole)
+				r.Get("/{id}/thread", offerService.ThreadHandler)
+				r.Post("/{id}/accept", offerService.AcceptHandler)
+				r.Post("/{id}/reject", offerService.RejectHandler)
+				r.Post("/{id}/counter", offerService.CounterHandler)
+				r.Post("/{id}/withdraw", offerService.WithdrawHandler)
+				r.Post("/{id}/sale", offerService.SaleHandler)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(access.RequireAdminOrAgent)
+				r.Get("/", offers.Read)
+				r.Get("/incoming", offers.GetMyData)
+			})
+		})
+		r.Route("/commissions", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(access.RequireAdminOrAgent)
+				r.Get("/my", commissions.GetMyData)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(access.RequireAdmin)
+				r.Get("/", commissions.Read)
+				r.Get("/balances", commissionService.BalancesHandler)
+				r.Post("/payouts", commissionService.PayoutsHandler)
+				r.Get("/plans", commissionPlans.Read)
+				r.Post("/plans", commissionPlans.Create)
+				r.Get("/plans/{id}", commissionPlans.GetByID)
+				r.Put("/plans/{id}", commissionPlans.Update)
+				r.Patch("/plans/{id}", commissionPlans.Patch)
+				r.Delete("/plans/{id}", commissionPlans.Delete)
+			})
+		})
+		r.Route("/documents", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(access.LoadRole)
+				r.Get("/", dealDocuments.List)
+				r.Post("/", dealDocuments.CreateHandler)
+				r.Get("/{id}", dealDocuments.GetHandler)
+				r.Post("/{id}/versions", dealDocuments.VersionHandler)
+				r.Get("/{id}/link", dealDocuments.LinkHandler)
+				r.Delete("/{id}", dealDocuments.DeleteHandler)
 			})
 		})
 		r.Route("/purchases", func(r chi.Router) {
-			r.Get("/", estate.Read[estate.Purchase])
-			r.Get("/my", estate.GetMyData[estate.Purchase])
-			r.Get("/{id}", estate.GetByID[estate.Purchase])
+			r.Get("/", purchases.Read)
+			r.Get("/my", purchases.GetMyData)
+			r.Get("/{id}", purchases.GetByID)
 			r.Group(func(r chi.Router) {
-				r.Use(estate.RequireAdminOrAgent)
-				r.Post("/", estate.Create[estate.Purchase])
-				r.Put("/{id}", estate.Update[estate.Purchase])
+				r.Use(access.RequireAdminOrAgent)
+				r.Post("/", purchases.Create)
+				r.Get("/export", purchases.Export)
+				r.Get("/{id}/history", estate.History[estate.Purchase](repos.Audit))
+				r.Put("/{id}", purchases.Update)
+				r.Patch("/{id}", purchases.Patch)
 			})
 		})
 		r.Route("/sales", func(r chi.Router) {
-			r.Get("/", estate.Read[estate.Sale])
-			r.Get("/my", estate.GetMyData[estate.Sale])
-			r.Get("/{id}", estate.GetByID[estate.Sale])
+			r.Get("/", sales.Read)
+			r.Get("/my", sales.GetMyData)
+			r.Get("/{id}", sales.GetByID)
 			r.Group(func(r chi.Router) {
-				r.Use(estate.RequireAdminOrAgent)
-				r.Post("/", estate.Create[estate.Sale])
-				r.Put("/{id}", estate.Update[estate.Sale])
+				r.Use(access.RequireAdminOrAgent)
+				r.Post("/", saleService.Create)
+				r.Get("/export", sales.Export)
+				r.Get("/{id}/history", estate.History[estate.Sale](repos.Audit))
+				r.Put("/{id}", sales.Update)
+				r.Patch("/{id}", sales.Patch)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(access.LoadRole)
+				r.Get("/{id}/documents", documentService.List)
+				r.Get("/{id}/contract.pdf", documentService.PDFHandler(estate.DocumentContract))
+				r.Get("/{id}/receipt.pdf", documentService.PDFHandler(estate.DocumentReceipt))
 			})
 		})
 	})
 	r.Group(func(r chi.Router) {
 		r.Use(jwtauth.Authenticator)
-		r.Use(estate.RequireAdmin)
+		r.Use(access.RequireAdmin)
 		r.Route("/admin", func(r chi.Router) {
 			// Управление пользователями
-			r.Get("/users", estate.Read[estate.User])
-			r.Put("/users/{id}/role", estate.Update[estate.User])
-			r.Delete("/users/{id}", estate.Delete[estate.User])
+			r.Get("/users", users.Read)
+			r.Put("/users/{id}/role", users.Update)
+			r.Patch("/users/{id}", users.Patch)
+			r.Delete("/users/{id}", users.Delete)
 
 			// Управление системой
-			r.Delete("/purchases/{id}", estate.Delete[estate.Purchase])
-			r.Delete("/sales/{id}", estate.Delete[estate.Sale])
+			r.Delete("/purchases/{id}", purchases.Delete)
+			r.Delete("/sales/{id}", sales.Delete)
+
+			// Шаблоны документов и выданные документы (поиск по checksum)
+			r.Get("/templates", templates.Read)
+			r.Post("/templates", templates.Create)
+			r.Get("/templates/{id}", templates.GetByID)
+			r.Put("/templates/{id}", templates.Update)
+			r.Patch("/templates/{id}", templates.Patch)
+			r.Delete("/templates/{id}", templates.Delete)
+			r.Get("/documents", documents.Read)
+
+			// Курсы валют к базовой
+			r.Post("/exchange-rates", rates.Create)
+			r.Put("/exchange-rates/{id}", rates.Update)
+			r.Patch("/exchange-rates/{id}", rates.Patch)
+			r.Delete("/exchange-rates/{id}", rates.Delete)
+
+			// Журнал изменений
+			r.Get("/audit", audit.Read)
+
+			// Корзина
+			r.Get("/trash/properties", properties.ReadTrash)
+			r.Get("/trash/purchases", purchases.ReadTrash)
+			r.Get("/trash/sales", sales.ReadTrash)
+			r.Post("/trash/properties/{id}/restore", properties.Restore)
+			r.Post("/trash/purchases/{id}/restore", purchases.Restore)
+			r.Post("/trash/sales/{id}/restore", sales.Restore)
 		})
 	})
-	fmt.Println("Server started on :3000")
-	http.ListenAndServe(":3000", r)
+	return r
 }
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example-app/pkg/estate"
+	"example-app/pkg/storage"
+	"example-app/pkg/store"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+)
+
+// testAPI — API поверх хранилищ в памяти, как его поднимает newRouter без базы.
+type testAPI struct {
+	t     *testing.T
+	srv   *httptest.Server
+	repos *estate.Repositories
+	users *estate.MemoryUsers
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	tokenAuth = jwtauth.New("HS256", []byte("test-secret"), nil)
+	repos := estate.NewMemoryRepositories()
+	users := estate.NewMemoryUsers(repos)
+	srv := httptest.NewServer(newRouter(repos, users, storage.NewLocal(t.TempDir())))
+	t.Cleanup(srv.Close)
+	return &testAPI{t: t, srv: srv, repos: repos, users: users}
+}
+
+// do выполняет запрос с токеном (если он задан) и возвращает код и тело ответа.
+func (a *testAPI) do(token, method, path, body string) (int, string) {
+	a.t.Helper()
+	req, err := http.NewRequest(method, a.srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+// expect — do с проверкой кода ответа; тело разбирается в out, если он не nil.
+func (a *testAPI) expect(want int, token, method, path, body string, out interface{}) {
+	a.t.Helper()
+	code, resp := a.do(token, method, path, body)
+	if code != want {
+		a.t.Fatalf("%s %s: status %d, want %d: %s", method, path, code, want, resp)
+	}
+	if out != nil {
+		if err := json.Unmarshal([]byte(resp), out); err != nil {
+			a.t.Fatalf("%s %s: %v: %s", method, path, err, resp)
+		}
+	}
+}
+
+// login входит через /login; пользователь с ролью «пользователь» сначала регистрируется
+// через /register, агенты и администраторы создаются напрямую, как в estatectl create-user.
+func (a *testAPI) login(email string, role int) string {
+	a.t.Helper()
+	if role == store.RoleUser {
+		a.expect(http.StatusCreated, "", "POST", "/register",
+			`{"username":"u","email":"`+email+`","password":"secret"}`, nil)
+	} else if _, err := a.users.CreateUser("staff", email, "secret", role); err != nil {
+		a.t.Fatal(err)
+	}
+	var resp store.LoginResponse
+	a.expect(http.StatusOK, "", "POST", "/login", `{"email":"`+email+`","password":"secret"}`, &resp)
+	if resp.RoleID != role {
+		a.t.Fatalf("login %s: role %d, want %d", email, resp.RoleID, role)
+	}
+	return resp.AccessToken
+}
+
+type propertyList struct {
+	Items []estate.Property `json:"items"`
+	Total int               `json:"total"`
+}
+
+func TestAuth(t *testing.T) {
+	api := newTestAPI(t)
+	api.login("user@example.com", store.RoleUser)
+
+	api.expect(http.StatusConflict, "", "POST", "/register",
+		`{"username":"u","email":"user@example.com","password":"other"}`, nil)
+	api.expect(http.StatusUnauthorized, "", "POST", "/login",
+		`{"email":"user@example.com","password":"wrong"}`, nil)
+	api.expect(http.StatusUnauthorized, "", "POST", "/login",
+		`{"email":"nobody@example.com","password":"secret"}`, nil)
+	api.expect(http.StatusUnauthorized, "", "GET", "/properties", "", nil)
+}
+
+func TestPropertyCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+
+	api.expect(http.StatusCreated, agent, "POST", "/properties",
+		`{"address":"ул. Рудаки, 10","type":"apartment","price":"120000","currency":"USD"}`, nil)
+	api.expect(http.StatusUnprocessableEntity, agent, "POST", "/properties", `{"type":"apartment","price":"1"}`, nil)
+
+	var mine propertyList
+	api.expect(http.StatusOK, agent, "GET", "/properties/my", "", &mine)
+	if mine.Total != 1 {
+		t.Fatalf("GET /properties/my: total %d, want 1", mine.Total)
+	}
+	p := mine.Items[0]
+	if p.Status != estate.StatusDraft || p.Price.String() != "120000.00" {
+		t.Fatalf("created property: status %q, price %s", p.Status, p.Price)
+	}
+	path := "/properties/" + strconv.Itoa(p.ID)
+
+	api.expect(http.StatusOK, agent, "PUT", path,
+		`{"address":"ул. Рудаки, 12","type":"apartment","price":"110000","currency":"USD"}`, nil)
+	var got estate.Property
+	api.expect(http.StatusOK, agent, "GET", path, "", &got)
+	if got.Address != "ул. Рудаки, 12" || got.Price.String() != "110000.00" {
+		t.Fatalf("updated property: %+v", got)
+	}
+
+	api.expect(http.StatusUnprocessableEntity, agent, "PUT", path, `{"type":"house","price":"1"}`, nil)
+
+	api.expect(http.StatusOK, agent, "DELETE", path, "", nil)
+	api.expect(http.StatusNotFound, agent, "GET", path, "", nil)
+}
+
+func TestPropertyFilters(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	admin := api.login("admin@example.com", store.RoleAdmin)
+	api.expect(http.StatusCreated, admin, "POST", "/admin/exchange-rates", `{"currency":"EUR","rate":"1.1"}`, nil)
+	for _, body := range []string{
+		`{"address":"Dushanbe, Rudaki 1","type":"apartment","price":"50000","currency":"USD"}`,
+		`{"address":"Dushanbe, Somoni 2","type":"house","price":"150000","currency":"USD"}`,
+		`{"address":"Khujand, Lenin 3","type":"apartment","price":"90000","currency":"USD"}`,
+		`{"address":"Khujand, Lenin 4","type":"apartment","price":"60","currency":"EUR"}`,
+	} {
+		api.expect(http.StatusCreated, agent, "POST", "/properties", body, nil)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// без ?currency= суммы сравниваются как есть
+		{"type=apartment&sort=price", []string{"Khujand, Lenin 4", "Dushanbe, Rudaki 1", "Khujand, Lenin 3"}},
+		{"price_min=60000&sort=-price", []string{"Dushanbe, Somoni 2", "Khujand, Lenin 3"}},
+		{"type=apartment&price_max=60000", []string{"Dushanbe, Rudaki 1", "Khujand, Lenin 4"}},
+		// с ?currency= — после пересчёта в неё; 1 EUR = 1.1 USD
+		{"type=apartment&price_max=50000&currency=eur&sort=price", []string{"Khujand, Lenin 4", "Dushanbe, Rudaki 1"}},
+		{"price_min=80000&currency=EUR&sort=-price", []string{"Dushanbe, Somoni 2", "Khujand, Lenin 3"}},
+		{"price_max=66&currency=USD", []string{"Khujand, Lenin 4"}},
+		{"type=office", nil},
+	}
+	for _, tt := range tests {
+		var list propertyList
+		api.expect(http.StatusOK, agent, "GET", "/properties?"+tt.query, "", &list)
+		var got []string
+		for _, p := range list.Items {
+			got = append(got, p.Address)
+		}
+		if list.Total != len(tt.want) || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("GET /properties?%s: %d %q, want %q", tt.query, list.Total, got, tt.want)
+		}
+	}
+
+	// одна запись пересчитывается так же, как список
+	var single struct {
+		Converted struct {
+			Currency string        `json:"currency"`
+			Price    estate.Amount `json:"price"`
+		} `json:"converted"`
+	}
+	api.expect(http.StatusOK, agent, "GET", "/properties/4?currency=USD", "", &single)
+	if single.Converted.Currency != "USD" || single.Converted.Price.String() != "66.00" {
+		t.Errorf("GET /properties/4?currency=USD: converted %v", single.Converted)
+	}
+
+	api.expect(http.StatusBadRequest, agent, "GET", "/properties?sort=owner_password", "", nil)
+	api.expect(http.StatusBadRequest, agent, "GET", "/properties?price_min=abc", "", nil)
+	api.expect(http.StatusBadRequest, agent, "GET", "/properties?sort=price&currency=EUR&cursor=", "", nil)
+}
+
+func TestPropertyLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	user := api.login("user@example.com", store.RoleUser)
+
+	api.expect(http.StatusCreated, agent, "POST", "/properties",
+		`{"address":"Rudaki 1","type":"apartment","price":"50000","currency":"USD"}`, nil)
+
+	api.expect(http.StatusForbidden, user, "POST", "/properties/1/transitions/publish", "", nil)
+	api.expect(http.StatusConflict, agent, "POST", "/properties/1/transitions/cancel_contract", "", nil)
+
+	var p estate.Property
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/publish", "", &p)
+	if p.Status != estate.StatusAvailable {
+		t.Fatalf("after publish: status %q", p.Status)
+	}
+	api.expect(http.StatusConflict, agent, "POST", "/properties/1/transitions/publish", "", nil)
+
+	var history struct {
+		Items []estate.PropertyTransition `json:"items"`
+	}
+	api.expect(http.StatusOK, agent, "GET", "/properties/1/transitions", "", &history)
+	if len(history.Items) != 1 || history.Items[0].FromStatus != estate.StatusDraft || history.Items[0].ToStatus != estate.StatusAvailable {
+		t.Fatalf("transitions: %+v", history.Items)
+	}
+}
+
+func TestOfferAccept(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	first := api.login("first@example.com", store.RoleUser)
+	second := api.login("second@example.com", store.RoleUser)
+
+	api.expect(http.StatusCreated, agent, "POST", "/properties",
+		`{"address":"Rudaki 1","type":"apartment","price":"50000","currency":"USD"}`, nil)
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/publish", "", nil)
+
+	offer := `{"property_id":1,"amount":"45000","expires_at":"2100-01-01T00:00:00Z"}`
+	var a, b estate.Offer
+	api.expect(http.StatusCreated, first, "POST", "/offers", offer, &a)
+	api.expect(http.StatusCreated, second, "POST", "/offers", offer, &b)
+
+	api.expect(http.StatusForbidden, first, "POST", "/offers/"+strconv.Itoa(a.ID)+"/accept", "", nil)
+	api.expect(http.StatusOK, agent, "POST", "/offers/"+strconv.Itoa(a.ID)+"/accept", "", nil)
+	api.expect(http.StatusConflict, agent, "POST", "/offers/"+strconv.Itoa(b.ID)+"/accept", "", nil)
+
+	var thread []estate.Offer
+	api.expect(http.StatusOK, second, "GET", "/offers/"+strconv.Itoa(b.ID)+"/thread", "", &thread)
+	if len(thread) != 1 || thread[0].Status != estate.OfferRejected {
+		t.Fatalf("other buyer's offer after accept: %+v", thread)
+	}
+
+	var p estate.Property
+	api.expect(http.StatusOK, agent, "GET", "/properties/1", "", &p)
+	if p.Status != estate.StatusUnderContract {
+		t.Fatalf("property after accept: status %q", p.Status)
+	}
+	api.expect(http.StatusConflict, second, "POST", "/offers", offer, nil)
+
+	// сделка сорвалась: принятое предложение закрыто, продать по нему нельзя
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/cancel_contract", "", nil)
+	api.expect(http.StatusConflict, agent, "POST", "/offers/"+strconv.Itoa(a.ID)+"/sale", "", nil)
+	api.expect(http.StatusCreated, second, "POST", "/offers", offer, nil)
+}
+
+func TestSavedSearchAlerts(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	user := api.login("user@example.com", store.RoleUser)
+	admin := api.login("admin@example.com", store.RoleAdmin)
+	api.expect(http.StatusCreated, admin, "POST", "/admin/exchange-rates", `{"currency":"EUR","rate":"1.1"}`, nil)
+
+	api.expect(http.StatusCreated, user, "POST", "/saved-searches", `{"name":"flats","query":"type=apartment"}`, nil)
+	api.expect(http.StatusCreated, user, "POST", "/saved-searches", `{"name":"cheap","query":"price_max=42000&currency=EUR"}`, nil)
+	api.expect(http.StatusUnprocessableEntity, user, "POST", "/saved-searches", `{"name":"bad","query":"price_max=abc"}`, nil)
+
+	reasons := func() []string {
+		var list struct {
+			Items []estate.Notification `json:"items"`
+		}
+		api.expect(http.StatusOK, user, "GET", "/notifications?sort=id", "", &list)
+		var got []string
+		for _, n := range list.Items {
+			got = append(got, strconv.Itoa(n.SearchID)+":"+n.Reason)
+		}
+		return got
+	}
+
+	// сверку с поисками делает фоновая задача, как RunAlerts
+	alerts := estate.NewAlertService(api.repos, estate.LogNotifier{})
+	match := func() {
+		if _, err := alerts.Match(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listing := `{"address":"Rudaki 1","type":"apartment","price":"50000","currency":"USD"}`
+	api.expect(http.StatusCreated, agent, "POST", "/properties", listing, nil)
+	match()
+	if got := reasons(); len(got) != 0 {
+		t.Fatalf("alerts for a draft: %q", got)
+	}
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/publish", "", nil)
+	match()
+	api.expect(http.StatusOK, agent, "PUT", "/properties/1", strings.Replace(listing, "50000", "45000", 1), nil)
+	match()
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/withdraw", "", nil)
+	api.expect(http.StatusOK, agent, "PUT", "/properties/1", strings.Replace(listing, "50000", "40000", 1), nil)
+	// повторная публикация — не новый объект
+	api.expect(http.StatusOK, admin, "POST", "/properties/1/transitions/relist", "", nil)
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/publish", "", nil)
+	match()
+
+	// 45000 USD ≈ 40909 EUR — теперь подходит и под «cheap»
+	want := []string{"1:" + estate.ReasonNew, "1:" + estate.ReasonPriceChanged, "2:" + estate.ReasonPriceChanged}
+	if got := reasons(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("alerts: %q, want %q", got, want)
+	}
+
+	n, err := alerts.Deliver(context.Background(), time.Now())
+	if err != nil || n != len(want) {
+		t.Fatalf("Deliver: %d, %v", n, err)
+	}
+	var list struct {
+		Items []estate.Notification `json:"items"`
+	}
+	api.expect(http.StatusOK, user, "GET", "/notifications", "", &list)
+	for _, n := range list.Items {
+		if n.Status != estate.NotificationSent || n.SentAt == nil {
+			t.Fatalf("delivered notification: %+v", n)
+		}
+	}
+}
+
+func TestSaleCommissions(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	admin := api.login("admin@example.com", store.RoleAdmin)
+	api.login("buyer@example.com", store.RoleUser)
+
+	api.expect(http.StatusCreated, admin, "POST", "/commissions/plans", `{"name":"default","kind":"flat","rate":3}`, nil)
+	api.expect(http.StatusCreated, agent, "POST", "/properties",
+		`{"address":"Rudaki 1","type":"apartment","price":"100000","currency":"USD"}`, nil)
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/publish", "", nil)
+
+	api.expect(http.StatusUnprocessableEntity, admin, "POST", "/sales",
+		`{"property_id":1,"buyer_id":3,"final_price":"100000","splits":[{"agent_id":1,"share":33.333},{"agent_id":2,"share":66.667}]}`, nil)
+	var sale estate.Sale
+	api.expect(http.StatusCreated, agent, "POST", "/sales", `{"property_id":1,"buyer_id":3,"final_price":"100000"}`, &sale)
+
+	var balances []estate.AgentBalance
+	api.expect(http.StatusOK, admin, "GET", "/commissions/balances", "", &balances)
+	if len(balances) != 1 || balances[0].AgentID != 1 || balances[0].Accrued.String() != "3000.00" {
+		t.Fatalf("balances: %+v", balances)
+	}
+	// объект и покупатель продажи не меняются ни через PUT, ни через PATCH
+	path := "/sales/" + strconv.Itoa(sale.ID)
+	api.expect(http.StatusUnprocessableEntity, agent, "PATCH", path, `{"buyer_id":2}`, nil)
+	api.expect(http.StatusUnprocessableEntity, agent, "PUT", path,
+		`{"property_id":2,"buyer_id":3,"final_price":"100000","currency":"USD"}`, nil)
+	api.expect(http.StatusOK, agent, "PATCH", path, `{"final_price":"99000"}`, nil)
+
+	// продажа с начислениями — финансовая запись, в корзину она не отправляется
+	api.expect(http.StatusConflict, admin, "DELETE", "/admin/sales/"+strconv.Itoa(sale.ID), "", nil)
+}
+
+func TestSaleDocumentsBlockTrash(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	admin := api.login("admin@example.com", store.RoleAdmin)
+	api.login("buyer@example.com", store.RoleUser)
+
+	for _, address := range []string{"Rudaki 1", "Rudaki 2"} {
+		api.expect(http.StatusCreated, agent, "POST", "/properties",
+			`{"address":"`+address+`","type":"apartment","price":"100000","currency":"USD"}`, nil)
+	}
+	var issued, plain estate.Sale
+	for id, sale := range map[int]*estate.Sale{1: &issued, 2: &plain} {
+		path := "/properties/" + strconv.Itoa(id)
+		api.expect(http.StatusOK, agent, "POST", path+"/transitions/publish", "", nil)
+		api.expect(http.StatusCreated, agent, "POST", "/sales",
+			`{"property_id":`+strconv.Itoa(id)+`,"buyer_id":3,"final_price":"100000"}`, sale)
+	}
+
+	api.expect(http.StatusOK, agent, "GET", "/sales/"+strconv.Itoa(issued.ID)+"/contract.pdf", "", nil)
+	api.expect(http.StatusConflict, admin, "DELETE", "/admin/sales/"+strconv.Itoa(issued.ID), "", nil)
+	code, body := api.do(admin, "DELETE", "/admin/sales/"+strconv.Itoa(plain.ID), "")
+	if code >= 300 {
+		t.Fatalf("trash sale without documents: status %d: %s", code, body)
+	}
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	other := api.login("other@example.com", store.RoleAgent)
+	api.login("client@example.com", store.RoleUser)
+
+	for _, address := range []string{"Rudaki 1", "Rudaki 2"} {
+		api.expect(http.StatusCreated, agent, "POST", "/properties",
+			`{"address":"`+address+`","type":"apartment","price":"50000","currency":"USD"}`, nil)
+	}
+	var first, second estate.Reservation
+	for id, res := range map[int]*estate.Reservation{1: &first, 2: &second} {
+		body := `{"property_id":` + strconv.Itoa(id) + `,"client_id":3,"deposit":"1000","expires_at":"2100-01-01T00:00:00Z"}`
+		api.expect(http.StatusOK, agent, "POST", "/properties/"+strconv.Itoa(id)+"/transitions/publish", "", nil)
+		api.expect(http.StatusForbidden, other, "POST", "/reservations", body, nil)
+		api.expect(http.StatusCreated, agent, "POST", "/reservations", body, res)
+	}
+
+	// договор и снятие с продажи закрывают бронь вместе с переходом
+	api.expect(http.StatusOK, agent, "POST", "/properties/1/transitions/contract", "", nil)
+	api.expect(http.StatusOK, agent, "POST", "/properties/2/transitions/withdraw", "", nil)
+	for _, res := range []estate.Reservation{first, second} {
+		var got estate.Reservation
+		api.expect(http.StatusOK, agent, "GET", "/reservations/"+strconv.Itoa(res.ID), "", &got)
+		if got.Status != estate.ReservationReleased {
+			t.Fatalf("reservation %d after property left reserved: status %q", res.ID, got.Status)
+		}
+	}
+}
+
+func TestDealDocumentAccess(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	other := api.login("other@example.com", store.RoleAgent)
+	admin := api.login("admin@example.com", store.RoleAdmin)
+	api.expect(http.StatusCreated, agent, "POST", "/properties",
+		`{"address":"Rudaki 1","type":"apartment","price":"50000","currency":"USD"}`, nil)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("entity_type", estate.EntityProperty)
+	form.WriteField("entity_id", "1")
+	form.WriteField("category", estate.CategoryTitleDeed)
+	part, _ := form.CreateFormFile("file", "deed.txt")
+	part.Write([]byte("title deed"))
+	form.Close()
+	req, _ := http.NewRequest("POST", api.srv.URL+"/documents", &body)
+	req.Header.Set("Authorization", "Bearer "+agent)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /documents: status %d", resp.StatusCode)
+	}
+
+	// агент видит документы только своих сделок, администратор — все
+	api.expect(http.StatusOK, agent, "GET", "/documents/1", "", nil)
+	api.expect(http.StatusOK, agent, "GET", "/documents?entity_type=property&entity_id=1", "", nil)
+	api.expect(http.StatusForbidden, other, "GET", "/documents/1", "", nil)
+	api.expect(http.StatusForbidden, other, "GET", "/documents/1/link", "", nil)
+	api.expect(http.StatusForbidden, other, "GET", "/documents?entity_type=property&entity_id=1", "", nil)
+	api.expect(http.StatusBadRequest, other, "GET", "/documents", "", nil)
+	api.expect(http.StatusOK, admin, "GET", "/documents", "", nil)
+	api.expect(http.StatusOK, admin, "GET", "/documents/1", "", nil)
+}
+
+func TestFavorites(t *testing.T) {
+	api := newTestAPI(t)
+	agent := api.login("agent@example.com", store.RoleAgent)
+	user := api.login("user@example.com", store.RoleUser)
+	for _, address := range []string{"Rudaki 1", "Rudaki 2", "Rudaki 3"} {
+		api.expect(http.StatusCreated, agent, "POST", "/properties",
+			`{"address":"`+address+`","type":"apartment","price":"50000","currency":"USD"}`, nil)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		api.expect(http.StatusCreated, user, "POST", "/properties/"+id+"/favorite", "", nil)
+	}
+	api.expect(http.StatusOK, agent, "PATCH", "/properties/1", `{"price":"45000"}`, nil)
+	api.expect(http.StatusOK, agent, "DELETE", "/properties/2", "", nil)
+
+	var list struct {
+		Items []estate.Favorite `json:"items"`
+	}
+	api.expect(http.StatusOK, user, "GET", "/favorites?sort=id", "", &list)
+	if len(list.Items) != 3 {
+		t.Fatalf("favorites: %+v", list.Items)
+	}
+	changed, removed, same := list.Items[0], list.Items[1], list.Items[2]
+	if changed.Property == nil || changed.Property.Address != "Rudaki 1" || !changed.PriceChanged {
+		t.Errorf("favorite with a new price: %+v", changed)
+	}
+	if !removed.Removed || removed.Property != nil {
+		t.Errorf("favorite of a trashed property: %+v", removed)
+	}
+	if same.Property == nil || same.Property.Address != "Rudaki 3" || same.PriceChanged {
+		t.Errorf("unchanged favorite: %+v", same)
+	}
+}
@@ -0,0 +1,606 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/store"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Как часто доставлять совпадения сохранённого поиска.
+const (
+	FrequencyInstant = "instant"
+	FrequencyDaily   = "daily"
+)
+
+// Почему объект попал в уведомление.
+const (
+	ReasonNew          = "new"
+	ReasonPriceChanged = "price_changed"
+)
+
+// Статусы уведомления в очереди.
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+)
+
+// deliverBatch — сколько уведомлений одной частоты доставляется за проход.
+const deliverBatch = 500
+
+var frequencies = []string{FrequencyInstant, FrequencyDaily}
+
+// SavedSearch — сохранённый поиск: Query — строка параметров в том же виде,
+// что у GET /properties (type=apartment&price_max=100000&currency=USD&lat=..&lng=..&radius_km=..).
+type SavedSearch struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Query     string    `json:"query" db:"query"`
+	Frequency string    `json:"frequency" db:"frequency"`
+	OwnerID   int       `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (s SavedSearch) GetNameTable() string {
+	return "saved_searches"
+}
+func (s SavedSearch) GetNameColumns() string {
+	return "name, query, frequency"
+}
+func (s SavedSearch) GetPlaceholder() string {
+	return "$1, $2, $3"
+}
+func (s SavedSearch) GetValues() []interface{} {
+	return []interface{}{
+		s.Name, s.Query, s.Frequency,
+	}
+}
+func (s SavedSearch) GetUpdatedAt() time.Time {
+	return s.UpdatedAt
+}
+func (s SavedSearch) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !containsString(frequencies, s.Frequency) {
+		return fmt.Errorf("frequency must be one of: %s", strings.Join(frequencies, ", "))
+	}
+	if _, err := s.listQuery(); err != nil {
+		return err
+	}
+	return nil
+}
+func (s SavedSearch) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"frequency": {Column: "frequency", Op: "=", Kind: KindString},
+	}
+}
+func (s SavedSearch) GetSortColumns() []string {
+	return []string{"id", "name", "created_at", "updated_at"}
+}
+
+// listQuery разбирает Query теми же правилами, что и список объектов.
+func (s SavedSearch) listQuery() (*ListQuery, error) {
+	values, err := url.ParseQuery(strings.TrimPrefix(s.Query, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %v", err)
+	}
+	q, err := parseListQuery(Property{}, values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %v", err)
+	}
+	// для одной записи важны только условия
+	q.After, q.Limit, q.Offset = nil, 0, 0
+	return q, nil
+}
+
+// Notification — совпадение объекта с сохранённым поиском в очереди доставки.
+// Price и Currency — цена объекта на момент совпадения.
+type Notification struct {
+	ID         int        `json:"id" db:"id"`
+	SearchID   int        `json:"search_id" db:"search_id"`
+	PropertyID int        `json:"property_id" db:"property_id"`
+	Reason     string     `json:"reason" db:"reason"`
+	Frequency  string     `json:"frequency" db:"frequency"`
+	Price      Amount     `json:"price" db:"price"`
+	Currency   Currency   `json:"currency" db:"currency"`
+	Status     string     `json:"status" db:"status"`
+	SentAt     *time.Time `json:"sent_at" db:"sent_at"`
+	OwnerID    int        `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	Converted  *Converted `json:"converted,omitempty" db:"-"`
+}
+
+func (n Notification) GetNameTable() string {
+	return "notifications"
+}
+func (n Notification) GetNameColumns() string {
+	return "search_id, property_id, reason, frequency, price, currency, status"
+}
+func (n Notification) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6, $7"
+}
+func (n Notification) GetValues() []interface{} {
+	return []interface{}{
+		n.SearchID, n.PropertyID, n.Reason, n.Frequency, n.Price, n.Currency, n.Status,
+	}
+}
+func (n Notification) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"search_id": {Column: "search_id", Op: "=", Kind: KindInt},
+		"status":    {Column: "status", Op: "=", Kind: KindString},
+		"reason":    {Column: "reason", Op: "=", Kind: KindString},
+	}
+}
+func (n Notification) GetSortColumns() []string {
+	return []string{"id", "created_at"}
+}
+func (n Notification) withConverted(c *Converter) Notification {
+	n.Converted = convertFields(c, n.Currency, map[string]Amount{"price": n.Price})
+	return n
+}
+
+// ListingChange — публикация объекта или изменение его цены, ещё не сверенное с
+// сохранёнными поисками; owner_id — владелец объекта. Очередь разбирает AlertService.Match.
+type ListingChange struct {
+	ID         int       `json:"id" db:"id"`
+	PropertyID int       `json:"property_id" db:"property_id"`
+	Reason     string    `json:"reason" db:"reason"`
+	OwnerID    int       `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+func (c ListingChange) GetNameTable() string {
+	return "listing_changes"
+}
+func (c ListingChange) GetNameColumns() string {
+	return "property_id, reason"
+}
+func (c ListingChange) GetPlaceholder() string {
+	return "$1, $2"
+}
+func (c ListingChange) GetValues() []interface{} {
+	return []interface{}{
+		c.PropertyID, c.Reason,
+	}
+}
+func (c ListingChange) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"property_id": {Column: "property_id", Op: "=", Kind: KindInt},
+		"reason":      {Column: "reason", Op: "=", Kind: KindString},
+	}
+}
+func (c ListingChange) GetSortColumns() []string {
+	return []string{"id", "created_at"}
+}
+
+// alertingProperties — хранилище объектов, которое в той же транзакции, что и
+// первая публикация объекта (переход из черновика в available) или изменение его
+// цены, ставит изменение в очередь listing_changes. Так совпадения не теряются,
+// откуда бы ни пришло изменение: API, импорт или estatectl. Сверка с сохранёнными
+// поисками идёт потом, в AlertService.Match, не задерживая блокировку объекта.
+type alertingProperties struct {
+	Repository[Property]
+	tx          Transactor
+	transitions Repository[PropertyTransition]
+	changes     Repository[ListingChange]
+}
+
+func watchSearches(properties Repository[Property], tx Transactor, transitions Repository[PropertyTransition], changes Repository[ListingChange]) Repository[Property] {
+	return &alertingProperties{Repository: properties, tx: tx, transitions: transitions, changes: changes}
+}
+
+// SetColumn ловит публикацию: для сохранённых поисков объект появляется, когда
+// впервые выходит из черновика, а не когда создаётся. Повторная публикация после
+// снятия с продажи (withdraw → relist → publish) новым объектом не считается.
+func (a *alertingProperties) SetColumn(ctx context.Context, id int, column string, value interface{}, check func(current Property) error) (Property, error) {
+	if column != "status" {
+		return a.Repository.SetColumn(ctx, id, column, value, check)
+	}
+	var updated Property
+	err := a.tx.InTx(ctx, func(ctx context.Context) error {
+		var before string
+		p, err := a.Repository.SetColumn(ctx, id, column, value, func(current Property) error {
+			before = current.Status
+			if check != nil {
+				return check(current)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		updated = p
+		if before != StatusDraft || p.Status != StatusAvailable {
+			return nil
+		}
+		published, err := a.publishedBefore(ctx, id)
+		if err != nil || published {
+			return err
+		}
+		return a.queue(ctx, ReasonNew, p)
+	})
+	return updated, err
+}
+
+// publishedBefore — объект уже бывал в available: переход текущей публикации
+// Lifecycle пишет в журнал после смены статуса, поэтому в выборку он не попадает.
+func (a *alertingProperties) publishedBefore(ctx context.Context, id int) (bool, error) {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "property_id", Op: "=", Value: id},
+			{Column: "to_status", Op: "=", Value: StatusAvailable},
+		},
+		Limit:   1,
+		NoTotal: true,
+	}
+	earlier, _, err := a.transitions.List(ctx, q)
+	return len(earlier) > 0, err
+}
+
+func (a *alertingProperties) Update(ctx context.Context, id int, mutate func(current Property) (Property, error)) (Property, error) {
+	var updated Property
+	err := a.tx.InTx(ctx, func(ctx context.Context) error {
+		var before Property
+		p, err := a.Repository.Update(ctx, id, func(current Property) (Property, error) {
+			before = current
+			return mutate(current)
+		})
+		if err != nil {
+			return err
+		}
+		updated = p
+		if p.Status != StatusAvailable || p.Price == before.Price && p.Currency.String() == before.Currency.String() {
+			return nil
+		}
+		return a.queue(ctx, ReasonPriceChanged, p)
+	})
+	return updated, err
+}
+
+func (a *alertingProperties) queue(ctx context.Context, reason string, p Property) error {
+	_, err := a.changes.Insert(ctx, p.OwnerID, ListingChange{PropertyID: p.ID, Reason: reason})
+	return err
+}
+
+// searchQuery — сохранённый поиск с разобранным запросом и курсами для его валюты.
+type searchQuery struct {
+	search SavedSearch
+	query  *ListQuery
+	conv   *Converter
+}
+
+// loadSearches разбирает все сохранённые поиски один раз на проход Match.
+func (s *AlertService) loadSearches(ctx context.Context) ([]searchQuery, error) {
+	var loaded []searchQuery
+	// курсы на момент сверки, по одному Converter на валюту поиска
+	converters := map[Currency]*Converter{}
+	err := s.searches.Each(ctx, &ListQuery{}, func(search SavedSearch) error {
+		q, err := search.listQuery()
+		if err != nil {
+			// запрос проверяется при сохранении; сломанный поиск не должен мешать объектам
+			log.Printf("saved search %d: %v", search.ID, err)
+			return nil
+		}
+		var conv *Converter
+		if q.converts() {
+			if conv = converters[q.Currency]; conv == nil {
+				if conv, err = loadConverter(ctx, s.rates, q.Currency); err != nil {
+					log.Printf("saved search %d: %v", search.ID, err)
+					return nil
+				}
+				converters[q.Currency] = conv
+			}
+		}
+		loaded = append(loaded, searchQuery{search: search, query: q, conv: conv})
+		return nil
+	})
+	return loaded, err
+}
+
+// Match сверяет очередь listing_changes с сохранёнными поисками и ставит
+// уведомления; возвращает количество разобранных изменений. Предлагаются только
+// объекты, которые всё ещё в available, и только чужие.
+func (s *AlertService) Match(ctx context.Context) (int, error) {
+	q := &ListQuery{SortColumn: "id", Limit: deliverBatch, NoTotal: true}
+	changes, _, err := s.changes.List(ctx, q)
+	if err != nil || len(changes) == 0 {
+		return 0, err
+	}
+	searches, err := s.loadSearches(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, c := range changes {
+		err := s.tx.InTx(ctx, func(ctx context.Context) error {
+			p, err := s.properties.Get(ctx, c.PropertyID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			if err == nil && p.Status == StatusAvailable {
+				for _, sq := range searches {
+					if p.OwnerID == sq.search.OwnerID || !matches(p, sq.query, sq.conv) {
+						continue
+					}
+					// владелец уведомления — получатель, поэтому вставляем по одному
+					_, err := s.notifications.Insert(ctx, sq.search.OwnerID, Notification{
+						SearchID:   sq.search.ID,
+						PropertyID: p.ID,
+						Reason:     c.Reason,
+						Frequency:  sq.search.Frequency,
+						Price:      p.Price,
+						Currency:   p.Currency,
+						Status:     NotificationPending,
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return s.changes.Delete(ctx, c.ID, nil)
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(changes), nil
+}
+
+// Notifier доставляет пользователю пачку уведомлений: мгновенные — по мере
+// появления, ежедневные — одним дайджестом.
+type Notifier interface {
+	Notify(ctx context.Context, userID int, frequency string, items []Notification) error
+}
+
+// LogNotifier пишет уведомления в лог сервера — канал по умолчанию.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, userID int, frequency string, items []Notification) error {
+	ids := make([]string, len(items))
+	for i, n := range items {
+		ids[i] = fmt.Sprintf("#%d (%s, %s %s)", n.PropertyID, n.Reason, n.Price, n.Currency)
+	}
+	log.Printf("Уведомление пользователю %d (%s): %s", userID, frequency, strings.Join(ids, ", "))
+	return nil
+}
+
+// AlertService ведёт сохранённые поиски, сверяет с ними изменения объектов и
+// доставляет накопленные уведомления.
+type AlertService struct {
+	tx            Transactor
+	properties    Repository[Property]
+	changes       Repository[ListingChange]
+	searches      Repository[SavedSearch]
+	notifications Repository[Notification]
+	rates         Repository[ExchangeRate]
+	notifier      Notifier
+}
+
+func NewAlertService(repos *Repositories, notifier Notifier) *AlertService {
+	return &AlertService{
+		tx:            repos.Tx,
+		properties:    repos.Properties,
+		changes:       repos.ListingChanges,
+		searches:      repos.SavedSearches,
+		notifications: repos.Notifications,
+		rates:         repos.Rates,
+		notifier:      notifier,
+	}
+}
+
+// Deliver сверяет накопленные изменения объектов с поисками, затем отправляет
+// мгновенные уведомления и ежедневные, накопленные до начала текущих суток
+// (дайджест уходит один раз после полуночи), и возвращает количество отправленных.
+func (s *AlertService) Deliver(ctx context.Context, now time.Time) (int, error) {
+	if _, err := s.Match(ctx); err != nil {
+		return 0, err
+	}
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	instant, err := s.deliver(ctx, now, FrequencyInstant, nil)
+	if err != nil {
+		return instant, err
+	}
+	daily, err := s.deliver(ctx, now, FrequencyDaily, &Condition{Column: "created_at", Op: "<", Value: midnight})
+	return instant + daily, err
+}
+
+func (s *AlertService) deliver(ctx context.Context, now time.Time, frequency string, extra *Condition) (int, error) {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "status", Op: "=", Value: NotificationPending},
+			{Column: "frequency", Op: "=", Value: frequency},
+		},
+		SortColumn: "id",
+		Limit:      deliverBatch,
+		NoTotal:    true,
+	}
+	if extra != nil {
+		q.Conditions = append(q.Conditions, *extra)
+	}
+	pending, _, err := s.notifications.List(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	byUser := map[int][]Notification{}
+	var users []int
+	for _, n := range pending {
+		if _, ok := byUser[n.OwnerID]; !ok {
+			users = append(users, n.OwnerID)
+		}
+		byUser[n.OwnerID] = append(byUser[n.OwnerID], n)
+	}
+	sent := 0
+	for _, userID := range users {
+		items := byUser[userID]
+		if err := s.notifier.Notify(ctx, userID, frequency, items); err != nil {
+			// остальным пользователям это не мешает; этот получит их в следующий проход
+			log.Printf("Notify user %d: %v", userID, err)
+			continue
+		}
+		for _, n := range items {
+			err := s.tx.InTx(ctx, func(ctx context.Context) error {
+				if _, err := s.notifications.SetColumn(ctx, n.ID, "status", NotificationSent, nil); err != nil {
+					return err
+				}
+				_, err := s.notifications.SetColumn(ctx, n.ID, "sent_at", now, nil)
+				return err
+			})
+			if err != nil {
+				return sent, err
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// RunAlerts периодически доставляет уведомления; запускается в отдельной горутине.
+func RunAlerts(interval time.Duration, s *AlertService) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.Deliver(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("Alerts error: %v", err)
+		} else if n > 0 {
+			log.Printf("Alerts: доставлено %d уведомлений", n)
+		}
+		<-ticker.C
+	}
+}
+
+// ownSearch — сохранённый поиск пользователя; чужой выглядит как несуществующий.
+func (s *AlertService) ownSearch(ctx context.Context, id, userID int) (SavedSearch, error) {
+	search, err := s.searches.Get(ctx, id)
+	if err != nil {
+		return search, err
+	}
+	if search.OwnerID != userID {
+		return SavedSearch{}, ErrNotFound
+	}
+	return search, nil
+}
+
+// searchParams — {id} и пользователь для маршрутов /saved-searches/{id}.
+func searchParams(w http.ResponseWriter, r *http.Request) (id, userID int, ok bool) {
+	if id, ok = urlID(w, r); !ok {
+		return
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return id, 0, false
+	}
+	return id, userID, true
+}
+
+func decodeSearch(w http.ResponseWriter, r *http.Request) (SavedSearch, bool) {
+	var search SavedSearch
+	if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return search, false
+	}
+	defer r.Body.Close()
+	if search.Frequency == "" {
+		search.Frequency = FrequencyInstant
+	}
+	if err := search.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return search, false
+	}
+	return search, true
+}
+
+// Create — POST /saved-searches с телом {"name", "query", "frequency"}; по умолчанию instant.
+func (s *AlertService) Create(w http.ResponseWriter, r *http.Request) {
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	search, ok := decodeSearch(w, r)
+	if !ok {
+		return
+	}
+	rows, err := s.searches.Insert(requestContext(r), userID, search)
+	if err != nil {
+		writeRepoError(w, "CreateSavedSearch", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, rows[0])
+}
+
+// GetHandler — GET /saved-searches/{id}.
+func (s *AlertService) GetHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := searchParams(w, r)
+	if !ok {
+		return
+	}
+	search, err := s.ownSearch(r.Context(), id, userID)
+	if err != nil {
+		writeRepoError(w, "GetSavedSearch", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, search)
+}
+
+// UpdateHandler — PUT /saved-searches/{id}: новые name, query и frequency.
+func (s *AlertService) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := searchParams(w, r)
+	if !ok {
+		return
+	}
+	next, ok := decodeSearch(w, r)
+	if !ok {
+		return
+	}
+	search, err := s.searches.Update(requestContext(r), id, func(current SavedSearch) (SavedSearch, error) {
+		if current.OwnerID != userID {
+			return current, ErrNotFound
+		}
+		return next, nil
+	})
+	if err != nil {
+		writeRepoError(w, "UpdateSavedSearch", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, search)
+}
+
+// DeleteHandler — DELETE /saved-searches/{id}; уведомления поиска удаляются вместе с ним.
+func (s *AlertService) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := searchParams(w, r)
+	if !ok {
+		return
+	}
+	err := s.tx.InTx(requestContext(r), func(ctx context.Context) error {
+		if _, err := s.ownSearch(ctx, id, userID); err != nil {
+			return err
+		}
+		q := &ListQuery{Conditions: []Condition{{Column: "search_id", Op: "=", Value: id}}, NoTotal: true}
+		notifications, _, err := s.notifications.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			if err := s.notifications.Delete(ctx, n.ID, nil); err != nil {
+				return err
+			}
+		}
+		return s.searches.Delete(ctx, id, nil)
+	})
+	if err != nil {
+		writeRepoError(w, "DeleteSavedSearch", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Операция завершилась успешно!"})
+}
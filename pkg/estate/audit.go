@@ -0,0 +1,186 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"example-app/pkg/store"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+)
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditImport  = "import"
+)
+
+// AuditEntry — запись журнала изменений. В before/after хранятся только
+// изменившиеся поля (для создания — вся запись в after, для удаления — в before).
+type AuditEntry struct {
+	ID        int             `json:"id" db:"id"`
+	ActorID   *int            `json:"actor_id" db:"actor_id"`
+	Action    string          `json:"action" db:"action"`
+	TableName string          `json:"table_name" db:"table_name"`
+	RecordID  int             `json:"record_id" db:"record_id"`
+	Before    *types.JSONText `json:"before" db:"before"`
+	After     *types.JSONText `json:"after" db:"after"`
+	IP        string          `json:"ip" db:"ip"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+func (a AuditEntry) GetNameTable() string {
+	return "audit_log"
+}
+func (a AuditEntry) GetNameColumns() string {
+	return "actor_id, action, table_name, record_id, before, after, ip"
+}
+func (a AuditEntry) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6, $7"
+}
+func (a AuditEntry) GetValues() []interface{} {
+	return []interface{}{
+		a.ActorID, a.Action, a.TableName, a.RecordID, a.Before, a.After, a.IP,
+	}
+}
+func (a AuditEntry) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"actor_id":   {Column: "actor_id", Op: "=", Kind: KindInt},
+		"action":     {Column: "action", Op: "=", Kind: KindString},
+		"table_name": {Column: "table_name", Op: "=", Kind: KindString},
+		"record_id":  {Column: "record_id", Op: "=", Kind: KindInt},
+		"ip":         {Column: "ip", Op: "=", Kind: KindString},
+		"date_from":  {Column: "created_at", Op: ">=", Kind: KindTime},
+		"date_to":    {Column: "created_at", Op: "<=", Kind: KindTime},
+	}
+}
+func (a AuditEntry) GetSortColumns() []string {
+	return []string{"id", "created_at", "actor_id", "table_name"}
+}
+
+// requestContext — контекст запроса с автором изменений для журнала.
+func requestContext(r *http.Request) context.Context {
+	a := Actor{IP: clientIP(r)}
+	if id, err := store.GetIDUser(r); err == nil {
+		a.UserID = &id
+	}
+	return WithActor(r.Context(), a)
+}
+
+// RecordAudit пишет запись журнала через tx — в той же транзакции, что и само изменение.
+func RecordAudit(tx sqlx.Execer, actorID *int, ip, action, table string, id int, before, after interface{}) error {
+	entry, err := newAuditEntry(actorID, ip, action, table, id, before, after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO audit_log ("+entry.GetNameColumns()+") VALUES ("+entry.GetPlaceholder()+")",
+		entry.GetValues()...,
+	)
+	return err
+}
+
+func newAuditEntry(actorID *int, ip, action, table string, id int, before, after interface{}) (AuditEntry, error) {
+	b, a, err := auditDiff(before, after)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	return AuditEntry{
+		ActorID:   actorID,
+		Action:    action,
+		TableName: table,
+		RecordID:  id,
+		Before:    b,
+		After:     a,
+		IP:        ip,
+	}, nil
+}
+
+// auditDiff оставляет в before/after только различающиеся поля.
+func auditDiff(before, after interface{}) (*types.JSONText, *types.JSONText, error) {
+	bm, err := toJSONMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	am, err := toJSONMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bm != nil && am != nil {
+		for k, v := range bm {
+			if reflect.DeepEqual(v, am[k]) {
+				delete(bm, k)
+				delete(am, k)
+			}
+		}
+	}
+	b, err := toJSONText(bm)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := toJSONText(am)
+	return b, a, err
+}
+
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	err = json.Unmarshal(raw, &m)
+	return m, err
+}
+
+func toJSONText(m map[string]interface{}) (*types.JSONText, error) {
+	if m == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	j := types.JSONText(raw)
+	return &j, nil
+}
+
+// clientIP — адрес клиента; X-Forwarded-For учитывается middleware.RealIP.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// History — журнал изменений одной записи T, от старых к новым.
+func History[T Helper](audit Repository[AuditEntry]) http.HandlerFunc {
+	var item T
+	table := item.GetNameTable()
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid ID format", http.StatusBadRequest)
+			return
+		}
+		lq, err := parseListQuery(AuditEntry{}, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lq.addCondition("table_name", "=", table)
+		lq.addCondition("record_id", "=", id)
+		writeList(w, r, lq, audit.List)
+	}
+}
@@ -0,0 +1,488 @@
+package estate
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Виды планов: flat — один процент от цены, tiered — ступени, как налоговая
+// шкала: ставка ступени применяется только к части цены внутри ступени.
+const (
+	PlanFlat   = "flat"
+	PlanTiered = "tiered"
+)
+
+// Статусы начисления.
+const (
+	CommissionAccrued = "accrued"
+	CommissionPaid    = "paid"
+)
+
+// CommissionTier — ступень плана: Rate процентов с части цены от From до From следующей ступени.
+// Границы ступеней — в валюте плана.
+type CommissionTier struct {
+	From Amount  `json:"from"`
+	Rate float64 `json:"rate"`
+}
+
+// CommissionTiers хранится в JSONB.
+type CommissionTiers []CommissionTier
+
+func (t CommissionTiers) Value() (driver.Value, error) {
+	if t == nil {
+		t = CommissionTiers{}
+	}
+	return json.Marshal(t)
+}
+
+func (t *CommissionTiers) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	case nil:
+		*t = nil
+		return nil
+	}
+	return fmt.Errorf("commission tiers: unsupported type %T", src)
+}
+
+// CommissionPlan — как считается комиссия агента. План с пустым agent_id действует
+// для всех агентов без своего плана.
+type CommissionPlan struct {
+	ID        int             `json:"id" db:"id"`
+	Name      string          `json:"name" db:"name"`
+	Kind      string          `json:"kind" db:"kind"`
+	Rate      float64         `json:"rate" db:"rate"`
+	Tiers     CommissionTiers `json:"tiers" db:"tiers"`
+	Currency  Currency        `json:"currency" db:"currency"`
+	AgentID   *int            `json:"agent_id" db:"agent_id"`
+	OwnerID   int             `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"-" db:"updated_at"`
+}
+
+func (p CommissionPlan) GetNameTable() string {
+	return "commission_plans"
+}
+func (p CommissionPlan) GetNameColumns() string {
+	return "name, kind, rate, tiers, currency, agent_id"
+}
+func (p CommissionPlan) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6"
+}
+func (p CommissionPlan) GetValues() []interface{} {
+	return []interface{}{
+		p.Name, p.Kind, p.Rate, p.Tiers, p.Currency, p.AgentID,
+	}
+}
+func (p CommissionPlan) GetUpdatedAt() time.Time {
+	return p.UpdatedAt
+}
+func (p CommissionPlan) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if err := p.Currency.Validate(); err != nil {
+		return err
+	}
+	validRate := func(r float64) bool { return r >= 0 && r <= 100 }
+	switch p.Kind {
+	case PlanFlat:
+		if !validRate(p.Rate) {
+			return fmt.Errorf("rate must be between 0 and 100")
+		}
+	case PlanTiered:
+		if len(p.Tiers) == 0 {
+			return fmt.Errorf("tiers are required for a tiered plan")
+		}
+		if p.Tiers[0].From != 0 {
+			return fmt.Errorf("first tier must start from 0")
+		}
+		for i, t := range p.Tiers {
+			if !validRate(t.Rate) {
+				return fmt.Errorf("tier %d: rate must be between 0 and 100", i+1)
+			}
+			if i > 0 && t.From <= p.Tiers[i-1].From {
+				return fmt.Errorf("tier %d: from must be greater than in the previous tier", i+1)
+			}
+		}
+	default:
+		return fmt.Errorf("kind must be %s or %s", PlanFlat, PlanTiered)
+	}
+	return nil
+}
+func (p CommissionPlan) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"kind":     {Column: "kind", Op: "=", Kind: KindString},
+		"agent_id": {Column: "agent_id", Op: "=", Kind: KindInt},
+	}
+}
+func (p CommissionPlan) GetSortColumns() []string {
+	return []string{"id", "name"}
+}
+
+// Calculate — комиссия по плану с цены price в валюте плана, до копеек.
+func (p CommissionPlan) Calculate(price Amount) Amount {
+	if p.Kind != PlanTiered {
+		return price.Percent(p.Rate)
+	}
+	var sum Amount
+	for i, t := range p.Tiers {
+		upper := price
+		if i+1 < len(p.Tiers) && p.Tiers[i+1].From < price {
+			upper = p.Tiers[i+1].From
+		}
+		if upper <= t.From {
+			break
+		}
+		sum += (upper - t.From).Percent(t.Rate)
+	}
+	return sum
+}
+
+// CalculateIn — комиссия с цены price в валюте currency, в той же валюте. Ступени
+// считаются в валюте плана, поэтому цена пересчитывается в неё по курсам conv
+// (Converter в валюту плана), а комиссия — обратно. Процент от цены от валюты не
+// зависит, поэтому flat-план и продажа в валюте плана курсов не требуют.
+func (p CommissionPlan) CalculateIn(price Amount, currency Currency, conv *Converter) (Amount, error) {
+	if p.Kind != PlanTiered || currency.String() == p.Currency.String() {
+		return p.Calculate(price), nil
+	}
+	inPlan, ok := conv.Convert(price, currency)
+	if !ok {
+		return 0, newStatusError(http.StatusUnprocessableEntity, "no exchange rate for %s", currency)
+	}
+	fee, _ := conv.convertBetween(p.Calculate(inPlan), conv.To, currency)
+	return fee, nil
+}
+
+// Commission — начисление агенту (owner_id) по продаже; share — его доля сделки в процентах.
+type Commission struct {
+	ID        int        `json:"id" db:"id"`
+	SaleID    int        `json:"sale_id" db:"sale_id"`
+	PlanID    *int       `json:"plan_id" db:"plan_id"`
+	SalePrice Amount     `json:"sale_price" db:"sale_price"`
+	Share     float64    `json:"share" db:"share"`
+	Amount    Amount     `json:"amount" db:"amount"`
+	Currency  Currency   `json:"currency" db:"currency"`
+	Status    string     `json:"status" db:"status"`
+	PaidAt    *time.Time `json:"paid_at" db:"paid_at"`
+	OwnerID   int        `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	Converted *Converted `json:"converted,omitempty" db:"-"`
+}
+
+func (c Commission) GetNameTable() string {
+	return "commissions"
+}
+func (c Commission) GetNameColumns() string {
+	return "sale_id, plan_id, sale_price, share, amount, currency"
+}
+func (c Commission) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6"
+}
+func (c Commission) GetValues() []interface{} {
+	return []interface{}{
+		c.SaleID, c.PlanID, c.SalePrice, c.Share, c.Amount, c.Currency,
+	}
+}
+func (c Commission) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"sale_id":   {Column: "sale_id", Op: "=", Kind: KindInt},
+		"status":    {Column: "status", Op: "=", Kind: KindString},
+		"owner_id":  {Column: "owner_id", Op: "=", Kind: KindInt},
+		"date_from": {Column: "created_at", Op: ">=", Kind: KindTime},
+		"date_to":   {Column: "created_at", Op: "<=", Kind: KindTime},
+	}
+}
+func (c Commission) GetSortColumns() []string {
+	return []string{"id", "amount", "created_at", "paid_at"}
+}
+func (c Commission) withConverted(cv *Converter) Commission {
+	c.Converted = convertFields(cv, c.Currency, map[string]Amount{"sale_price": c.SalePrice, "amount": c.Amount})
+	return c
+}
+func (c Commission) withDefaults() Commission {
+	if c.Status == "" {
+		c.Status = CommissionAccrued
+	}
+	return c
+}
+
+// CommissionSplit — доля агента в сделке, в процентах; доли одной продажи дают 100.
+type CommissionSplit struct {
+	AgentID int     `json:"agent_id"`
+	Share   float64 `json:"share"`
+}
+
+func validateSplits(splits []CommissionSplit) error {
+	seen := map[int]bool{}
+	var total int64
+	for _, s := range splits {
+		if s.AgentID <= 0 {
+			return fmt.Errorf("splits: agent_id is required")
+		}
+		if seen[s.AgentID] {
+			return fmt.Errorf("splits: agent %d is listed twice", s.AgentID)
+		}
+		if s.Share <= 0 {
+			return fmt.Errorf("splits: share must be positive")
+		}
+		// share хранится как NUMERIC(5, 2): больше двух знаков молча округлились бы
+		cents := math.Round(s.Share * 100)
+		if math.Abs(s.Share*100-cents) > 1e-6 {
+			return fmt.Errorf("splits: share must have at most two decimal places")
+		}
+		seen[s.AgentID] = true
+		total += int64(cents)
+	}
+	if len(splits) > 0 && total != 10000 {
+		return fmt.Errorf("splits: shares must add up to 100")
+	}
+	return nil
+}
+
+// AgentBalance — сводка начислений агента в одной валюте.
+type AgentBalance struct {
+	AgentID     int      `json:"agent_id"`
+	Currency    Currency `json:"currency"`
+	Accrued     Amount   `json:"accrued"`
+	Paid        Amount   `json:"paid"`
+	Outstanding Amount   `json:"outstanding"`
+}
+
+// CommissionService начисляет комиссии по продажам и отмечает выплаты.
+type CommissionService struct {
+	tx          Transactor
+	plans       Repository[CommissionPlan]
+	commissions Repository[Commission]
+	rates       Repository[ExchangeRate]
+	sales       Repository[Sale]
+}
+
+func NewCommissionService(repos *Repositories) *CommissionService {
+	return &CommissionService{
+		tx:          repos.Tx,
+		plans:       repos.CommissionPlans,
+		commissions: repos.Commissions,
+		rates:       repos.Rates,
+		sales:       repos.Sales,
+	}
+}
+
+// planFor — план агента или план по умолчанию; ErrNotFound, если нет ни того, ни другого.
+func (s *CommissionService) planFor(ctx context.Context, agentID int) (CommissionPlan, error) {
+	q := &ListQuery{Conditions: []Condition{{Column: "agent_id", Op: "=", Value: agentID}}, Limit: 1, NoTotal: true}
+	plans, _, err := s.plans.List(ctx, q)
+	if err != nil {
+		return CommissionPlan{}, err
+	}
+	if len(plans) > 0 {
+		return plans[0], nil
+	}
+	return s.defaultPlan(ctx)
+}
+
+// defaultPlan — план без agent_id; условие IS NULL в ListQuery не выражается,
+// поэтому план ищется обходом (планов единицы).
+func (s *CommissionService) defaultPlan(ctx context.Context) (CommissionPlan, error) {
+	var found *CommissionPlan
+	err := s.plans.Each(ctx, &ListQuery{SortColumn: "id"}, func(p CommissionPlan) error {
+		if p.AgentID == nil {
+			found = &p
+		}
+		return nil
+	})
+	if err != nil {
+		return CommissionPlan{}, err
+	}
+	if found == nil {
+		return CommissionPlan{}, ErrNotFound
+	}
+	return *found, nil
+}
+
+// Accrue начисляет комиссии по продаже: каждому агенту из splits — его долю
+// комиссии по его плану, в валюте продажи. Агент без плана (и без плана по
+// умолчанию) не получает начисления.
+func (s *CommissionService) Accrue(ctx context.Context, sale Sale, splits []CommissionSplit) ([]Commission, error) {
+	var accrued []Commission
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		for _, split := range splits {
+			plan, err := s.planFor(ctx, split.AgentID)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			fee, err := s.calculate(ctx, plan, sale)
+			if err != nil {
+				return err
+			}
+			planID := plan.ID
+			c := Commission{
+				SaleID:    sale.ID,
+				PlanID:    &planID,
+				SalePrice: sale.FinalPrice,
+				Share:     split.Share,
+				Amount:    fee.Percent(split.Share),
+				Currency:  sale.Currency,
+			}
+			rows, err := s.commissions.Insert(ctx, split.AgentID, c)
+			if err != nil {
+				return err
+			}
+			accrued = append(accrued, rows...)
+		}
+		return nil
+	})
+	return accrued, err
+}
+
+// calculate — комиссия по плану с продажи; курсы загружаются, только если ступени
+// плана в другой валюте.
+func (s *CommissionService) calculate(ctx context.Context, plan CommissionPlan, sale Sale) (Amount, error) {
+	var conv *Converter
+	if plan.Kind == PlanTiered && plan.Currency.String() != sale.Currency.String() {
+		var err error
+		conv, err = loadConverter(ctx, s.rates, Currency(plan.Currency.String()))
+		if errors.Is(err, errUnknownCurrency) {
+			return 0, newStatusError(http.StatusUnprocessableEntity, "no exchange rate for plan currency %s", plan.Currency)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return plan.CalculateIn(sale.FinalPrice, sale.Currency, conv)
+}
+
+// Pay отмечает начисления выплаченными; уже выплаченное — ErrConflict, и вся выплата откатывается.
+func (s *CommissionService) Pay(ctx context.Context, ids []int) ([]Commission, error) {
+	var paid []Commission
+	now := time.Now()
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		for _, id := range ids {
+			_, err := s.commissions.SetColumn(ctx, id, "status", CommissionPaid, func(current Commission) error {
+				if current.Status != CommissionAccrued {
+					return fmt.Errorf("%w: commission %d is already paid", ErrConflict, id)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			c, err := s.commissions.SetColumn(ctx, id, "paid_at", now, nil)
+			if err != nil {
+				return err
+			}
+			paid = append(paid, c)
+		}
+		return nil
+	})
+	return paid, err
+}
+
+// Balances — начислено, выплачено и к выплате по каждому агенту и валюте; agentID 0 — все агенты.
+// Начисления по продажам в корзине не учитываются.
+func (s *CommissionService) Balances(ctx context.Context, agentID int) ([]AgentBalance, error) {
+	trashed := map[int]bool{}
+	err := s.sales.Each(ctx, &ListQuery{Trash: true}, func(sale Sale) error {
+		trashed[sale.ID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	q := &ListQuery{NoTotal: true}
+	if agentID != 0 {
+		q.Conditions = []Condition{{Column: "owner_id", Op: "=", Value: agentID}}
+	}
+	type key struct {
+		agentID  int
+		currency string
+	}
+	byAgent := map[key]*AgentBalance{}
+	err = s.commissions.Each(ctx, q, func(c Commission) error {
+		if trashed[c.SaleID] {
+			return nil
+		}
+		k := key{c.OwnerID, c.Currency.String()}
+		b, ok := byAgent[k]
+		if !ok {
+			b = &AgentBalance{AgentID: c.OwnerID, Currency: Currency(k.currency)}
+			byAgent[k] = b
+		}
+		b.Accrued += c.Amount
+		if c.Status == CommissionPaid {
+			b.Paid += c.Amount
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]AgentBalance, 0, len(byAgent))
+	for _, b := range byAgent {
+		b.Outstanding = b.Accrued - b.Paid
+		balances = append(balances, *b)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].AgentID != balances[j].AgentID {
+			return balances[i].AgentID < balances[j].AgentID
+		}
+		return balances[i].Currency < balances[j].Currency
+	})
+	return balances, nil
+}
+
+// PayoutsHandler — POST /commissions/payouts с телом {"ids": [...]}.
+func (s *CommissionService) PayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if len(body.IDs) == 0 {
+		http.Error(w, "ids are required", http.StatusUnprocessableEntity)
+		return
+	}
+	paid, err := s.Pay(requestContext(r), body.IDs)
+	if err != nil {
+		writeRepoError(w, "Payout", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, paid)
+}
+
+// BalancesHandler — GET /commissions/balances[?agent_id=N].
+func (s *CommissionService) BalancesHandler(w http.ResponseWriter, r *http.Request) {
+	agentID := 0
+	if v := r.URL.Query().Get("agent_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid agent_id", http.StatusBadRequest)
+			return
+		}
+		agentID = id
+	}
+	balances, err := s.Balances(r.Context(), agentID)
+	if err != nil {
+		writeRepoError(w, "Balances", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
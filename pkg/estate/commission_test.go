@@ -0,0 +1,82 @@
+package estate
+
+import "testing"
+
+func TestCommissionPlanCalculate(t *testing.T) {
+	tiered := CommissionPlan{Kind: PlanTiered, Tiers: CommissionTiers{
+		{From: 0, Rate: 5},
+		{From: 10000000, Rate: 3},
+		{From: 50000000, Rate: 1},
+	}}
+	tests := []struct {
+		name  string
+		plan  CommissionPlan
+		price Amount
+		want  Amount
+	}{
+		{"flat", CommissionPlan{Kind: PlanFlat, Rate: 3}, 10000000, 300000},
+		{"flat rounds half up", CommissionPlan{Kind: PlanFlat, Rate: 2.5}, 101, 3},
+		{"flat zero price", CommissionPlan{Kind: PlanFlat, Rate: 3}, 0, 0},
+		{"first tier only", tiered, 5000000, 250000},
+		{"tier boundary", tiered, 10000000, 500000},
+		{"two tiers", tiered, 20000000, 800000},
+		{"all tiers", tiered, 60000000, 1800000},
+		{"zero price", tiered, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.plan.Calculate(tt.price); got != tt.want {
+			t.Errorf("%s: Calculate(%s) = %s, want %s", tt.name, tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestCommissionPlanCalculateIn(t *testing.T) {
+	// 1 TJS = 0.1 USD: ступени плана в долларах
+	conv := &Converter{To: "USD", rates: map[Currency]Rate{"USD": baseRate, "TJS": 10000000}}
+	tiered := CommissionPlan{Kind: PlanTiered, Currency: "USD", Tiers: CommissionTiers{
+		{From: 0, Rate: 5},
+		{From: 10000000, Rate: 1},
+	}}
+	tests := []struct {
+		name     string
+		plan     CommissionPlan
+		price    Amount
+		currency Currency
+		want     Amount
+		wantErr  bool
+	}{
+		{"plan currency", tiered, 20000000, "USD", 600000, false},
+		// 2 000 000 TJS = 200 000 USD: 5000 + 1000 USD = 60 000 TJS
+		{"converted", tiered, 200000000, "TJS", 6000000, false},
+		{"flat needs no rate", CommissionPlan{Kind: PlanFlat, Rate: 3, Currency: "USD"}, 10000, "KZT", 300, false},
+		{"no rate", tiered, 10000, "KZT", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.plan.CalculateIn(tt.price, tt.currency, conv)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: CalculateIn(%s %s) = %s, %v; want %s, error %v", tt.name, tt.price, tt.currency, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSplits(t *testing.T) {
+	tests := []struct {
+		name    string
+		splits  []CommissionSplit
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"whole", []CommissionSplit{{AgentID: 1, Share: 100}}, false},
+		{"halves", []CommissionSplit{{AgentID: 1, Share: 50}, {AgentID: 2, Share: 50}}, false},
+		{"cents", []CommissionSplit{{AgentID: 1, Share: 33.33}, {AgentID: 2, Share: 66.67}}, false},
+		{"too precise", []CommissionSplit{{AgentID: 1, Share: 33.333}, {AgentID: 2, Share: 66.667}}, true},
+		{"short of 100", []CommissionSplit{{AgentID: 1, Share: 60}, {AgentID: 2, Share: 30}}, true},
+		{"agent twice", []CommissionSplit{{AgentID: 1, Share: 50}, {AgentID: 1, Share: 50}}, true},
+		{"zero share", []CommissionSplit{{AgentID: 1, Share: 100}, {AgentID: 2, Share: 0}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateSplits(tt.splits); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateSplits = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
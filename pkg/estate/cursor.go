@@ -0,0 +1,144 @@
+package estate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// CursorResponse — ответ в cursor-режиме: без общего количества, только токен следующей страницы.
+type CursorResponse[T Helper] struct {
+	Items      []T    `json:"items"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursorToken — содержимое курсора: позиция последней отданной строки и сортировка,
+// для которой она была вычислена.
+type cursorToken struct {
+	Col   string      `json:"c"`
+	Dir   string      `json:"d"`
+	Value interface{} `json:"v,omitempty"`
+	ID    int         `json:"id"`
+}
+
+// signingSecret — ключ подписи из переменной name; без неё — JWT_SECRET.
+func signingSecret(name string) []byte {
+	if s := os.Getenv(name); s != "" {
+		return []byte(s)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func cursorSecret() []byte {
+	return signingSecret("CURSOR_SECRET")
+}
+
+func signCursor(payload string) string {
+	mac := hmac.New(sha256.New, cursorSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func encodeCursor(t cursorToken) (string, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + signCursor(payload), nil
+}
+
+func decodeCursor(s string) (cursorToken, error) {
+	var t cursorToken
+	payload, sig, ok := strings.Cut(s, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signCursor(payload))) {
+		return t, fmt.Errorf("invalid cursor")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return t, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return t, fmt.Errorf("invalid cursor")
+	}
+	return t, nil
+}
+
+// sortDir — направление сортировки в том виде, в каком оно записывается в курсор.
+func (q *ListQuery) sortDir() string {
+	if q.SortDesc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// applyCursor переносит позицию из курсора в keyset-условие выборки.
+func (q *ListQuery) applyCursor() error {
+	if q.cursor == "" {
+		return nil
+	}
+	t, err := decodeCursor(q.cursor)
+	if err != nil {
+		return err
+	}
+	// курсор действителен только для той сортировки, в которой был выдан
+	if t.Col != q.SortColumn || t.Dir != q.sortDir() {
+		return fmt.Errorf("cursor does not match sort order")
+	}
+	q.After = &Keyset{Value: t.Value, ID: t.ID}
+	return nil
+}
+
+// nextCursor строит курсор по последней строке страницы.
+func (q *ListQuery) nextCursor(last interface{}) (string, error) {
+	t := cursorToken{Col: q.SortColumn, Dir: q.sortDir(), ID: recordID(last)}
+	if q.SortColumn != "id" {
+		f, ok := columnField(reflect.ValueOf(last), q.SortColumn)
+		if !ok {
+			return "", fmt.Errorf("no column %s", q.SortColumn)
+		}
+		t.Value = f.Interface()
+	}
+	return encodeCursor(t)
+}
+
+func writeCursorList[T Helper](w http.ResponseWriter, r *http.Request, lq *ListQuery, list listFunc[T]) {
+	if err := lq.applyCursor(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// берём на одну строку больше, чтобы понять, есть ли следующая страница
+	limit := lq.Limit
+	q := *lq
+	q.Limit = limit + 1
+	result, _, err := list(r.Context(), &q)
+	if err != nil {
+		log.Println("List Cursor error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	convertAll(r.Context(), result)
+	resp := CursorResponse[T]{Limit: limit}
+	if len(result) > limit {
+		result = result[:limit]
+		next, err := lq.nextCursor(result[len(result)-1])
+		if err != nil {
+			log.Println("List Cursor encode error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		resp.NextCursor = next
+	}
+	resp.Items = result
+	writeJSONWithETag(w, r, resp)
+}
@@ -0,0 +1,65 @@
+package estate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "test-secret")
+	tests := []cursorToken{
+		{Col: "id", Dir: "asc", ID: 1},
+		{Col: "price", Dir: "desc", Value: "50000.00", ID: 42},
+		{Col: "created_at", Dir: "asc", Value: "2024-01-02T03:04:05Z", ID: 7},
+		{Col: "price", Dir: "desc", ID: 3}, // NULL в колонке сортировки
+	}
+	for _, tt := range tests {
+		s, err := encodeCursor(tt)
+		if err != nil {
+			t.Fatalf("encodeCursor(%+v): %v", tt, err)
+		}
+		got, err := decodeCursor(s)
+		if err != nil {
+			t.Fatalf("decodeCursor(%q): %v", s, err)
+		}
+		if !reflect.DeepEqual(got, tt) {
+			t.Errorf("round trip: got %+v, want %+v", got, tt)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "test-secret")
+	valid, err := encodeCursor(cursorToken{Col: "id", Dir: "asc", ID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(valid, ".")
+	forged, err := encodeCursor(cursorToken{Col: "id", Dir: "asc", ID: 999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"payload of another cursor", forgedPayload + "." + sig},
+		{"bad signature", payload + ".AAAA"},
+		{"not base64", "!!!." + signCursor("!!!")},
+		{"not json", "bm90LWpzb24." + signCursor("bm90LWpzb24")},
+	}
+	for _, tt := range tests {
+		if _, err := decodeCursor(tt.in); err == nil {
+			t.Errorf("%s: decodeCursor(%q) accepted", tt.name, tt.in)
+		}
+	}
+
+	t.Setenv("CURSOR_SECRET", "rotated")
+	if _, err := decodeCursor(valid); err == nil {
+		t.Error("cursor signed with the old secret accepted")
+	}
+}
@@ -0,0 +1,658 @@
+package estate
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/storage"
+	"example-app/pkg/store"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// К чему прикрепляется документ сделки.
+const (
+	EntityProperty = "property"
+	EntityPurchase = "purchase"
+	EntitySale     = "sale"
+)
+
+// Категории документов сделки.
+const (
+	CategoryContract        = "contract"
+	CategoryTitleDeed       = "title_deed"
+	CategoryIDDocument      = "id_document"
+	CategoryPowerOfAttorney = "power_of_attorney"
+	CategoryOther           = "other"
+)
+
+const (
+	maxDealDocumentSize = 20 << 20
+	// downloadTTL — сколько действует подписанная ссылка на скачивание.
+	downloadTTL = 5 * time.Minute
+)
+
+var (
+	dealEntities   = []string{EntityProperty, EntityPurchase, EntitySale}
+	dealCategories = []string{CategoryContract, CategoryTitleDeed, CategoryIDDocument, CategoryPowerOfAttorney, CategoryOther}
+)
+
+// DealDocument — документ, прикреплённый к объекту, покупке или продаже (договор,
+// свидетельство о собственности, удостоверение личности). Файлы — в DealDocumentVersion,
+// Version — номер последней версии.
+type DealDocument struct {
+	ID         int                   `json:"id" db:"id"`
+	EntityType string                `json:"entity_type" db:"entity_type"`
+	EntityID   int                   `json:"entity_id" db:"entity_id"`
+	Category   string                `json:"category" db:"category"`
+	Title      string                `json:"title" db:"title"`
+	Version    int                   `json:"version" db:"version"`
+	OwnerID    int                   `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time             `json:"updated_at" db:"updated_at"`
+	Versions   []DealDocumentVersion `json:"versions,omitempty" db:"-"`
+}
+
+func (d DealDocument) GetNameTable() string {
+	return "deal_documents"
+}
+func (d DealDocument) GetNameColumns() string {
+	return "entity_type, entity_id, category, title, version"
+}
+func (d DealDocument) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5"
+}
+func (d DealDocument) GetValues() []interface{} {
+	return []interface{}{
+		d.EntityType, d.EntityID, d.Category, d.Title, d.Version,
+	}
+}
+func (d DealDocument) GetUpdatedAt() time.Time {
+	return d.UpdatedAt
+}
+func (d DealDocument) Validate() error {
+	if !containsString(dealEntities, d.EntityType) {
+		return fmt.Errorf("entity_type must be one of: %s", strings.Join(dealEntities, ", "))
+	}
+	if d.EntityID <= 0 {
+		return fmt.Errorf("entity_id is required")
+	}
+	if !containsString(dealCategories, d.Category) {
+		return fmt.Errorf("category must be one of: %s", strings.Join(dealCategories, ", "))
+	}
+	if strings.TrimSpace(d.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	return nil
+}
+func (d DealDocument) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"entity_type": {Column: "entity_type", Op: "=", Kind: KindString},
+		"entity_id":   {Column: "entity_id", Op: "=", Kind: KindInt},
+		"category":    {Column: "category", Op: "=", Kind: KindString},
+	}
+}
+func (d DealDocument) GetSortColumns() []string {
+	return []string{"id", "created_at", "updated_at"}
+}
+
+// DealDocumentVersion — одна версия файла документа; содержимое лежит в хранилище
+// файлов под StorageKey, Checksum — sha256 от него.
+type DealDocumentVersion struct {
+	ID          int       `json:"id" db:"id"`
+	DocumentID  int       `json:"document_id" db:"document_id"`
+	Version     int       `json:"version" db:"version"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int       `json:"size" db:"size"`
+	Checksum    string    `json:"checksum" db:"checksum"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	OwnerID     int       `json:"owner_id" db:"owner_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+func (v DealDocumentVersion) GetNameTable() string {
+	return "deal_document_versions"
+}
+func (v DealDocumentVersion) GetNameColumns() string {
+	return "document_id, version, file_name, content_type, size, checksum, storage_key"
+}
+func (v DealDocumentVersion) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6, $7"
+}
+func (v DealDocumentVersion) GetValues() []interface{} {
+	return []interface{}{
+		v.DocumentID, v.Version, v.FileName, v.ContentType, v.Size, v.Checksum, v.StorageKey,
+	}
+}
+func (v DealDocumentVersion) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"document_id": {Column: "document_id", Op: "=", Kind: KindInt},
+		"checksum":    {Column: "checksum", Op: "=", Kind: KindString},
+	}
+}
+func (v DealDocumentVersion) GetSortColumns() []string {
+	return []string{"id", "version", "created_at"}
+}
+
+// DealFile — загруженный файл, ещё не сохранённый.
+type DealFile struct {
+	Name string
+	Data []byte
+}
+
+// DownloadLink — подписанная ссылка на версию документа, действует до ExpiresAt.
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	Version   int       `json:"version"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DealDocumentService ведёт документы сделок. Видят и загружают их агенты,
+// администраторы и участники сделки: владелец объекта, продавец и агент покупки,
+// покупатель, продавец и агент продажи. Скачивание — только по подписанной ссылке.
+type DealDocumentService struct {
+	tx          Transactor
+	documents   Repository[DealDocument]
+	versions    Repository[DealDocumentVersion]
+	properties  Repository[Property]
+	purchases   Repository[Purchase]
+	sales       Repository[Sale]
+	transitions Repository[PropertyTransition]
+	files       storage.Storage
+}
+
+func NewDealDocumentService(repos *Repositories, files storage.Storage) *DealDocumentService {
+	return &DealDocumentService{
+		tx:          repos.Tx,
+		documents:   repos.DealDocuments,
+		versions:    repos.DealVersions,
+		properties:  repos.Properties,
+		purchases:   repos.Purchases,
+		sales:       repos.Sales,
+		transitions: repos.Transitions,
+		files:       files,
+	}
+}
+
+// Parties — участники сделки, к которой прикрепляются документы; ErrNotFound, если её нет.
+func (s *DealDocumentService) Parties(ctx context.Context, entityType string, entityID int) ([]int, error) {
+	switch entityType {
+	case EntityProperty:
+		p, err := s.properties.Get(ctx, entityID)
+		return []int{p.OwnerID}, err
+	case EntityPurchase:
+		p, err := s.purchases.Get(ctx, entityID)
+		return []int{p.SellerID, p.OwnerID}, err
+	case EntitySale:
+		sale, err := s.sales.Get(ctx, entityID)
+		if err != nil {
+			return nil, err
+		}
+		sellerID, err := saleSeller(ctx, s.transitions, sale)
+		return []int{sale.BuyerID, sale.OwnerID, sellerID}, err
+	}
+	return nil, newStatusError(http.StatusUnprocessableEntity, "entity_type must be one of: %s", strings.Join(dealEntities, ", "))
+}
+
+// canAccess — администраторы видят все документы, остальные (и агенты) — только
+// сделок, в которых участвуют: агент — как ведущий объект, покупку или продажу.
+func (s *DealDocumentService) canAccess(ctx context.Context, userID int, entityType string, entityID int) error {
+	if role, _ := roleFrom(ctx); role == store.RoleAdmin {
+		return nil
+	}
+	parties, err := s.Parties(ctx, entityType, entityID)
+	if err != nil {
+		return err
+	}
+	if !containsInt(parties, userID) {
+		return newStatusError(http.StatusForbidden, "Forbidden")
+	}
+	return nil
+}
+
+// store сохраняет файл под случайным ключом и возвращает заготовку версии.
+func (s *DealDocumentService) store(ctx context.Context, f DealFile) (DealDocumentVersion, error) {
+	if len(f.Data) == 0 {
+		return DealDocumentVersion{}, newStatusError(http.StatusUnprocessableEntity, "file is empty")
+	}
+	if len(f.Data) > maxDealDocumentSize {
+		return DealDocumentVersion{}, newStatusError(http.StatusRequestEntityTooLarge, "file is larger than %d MB", maxDealDocumentSize>>20)
+	}
+	id, err := randomKey()
+	if err != nil {
+		return DealDocumentVersion{}, err
+	}
+	sum := sha256.Sum256(f.Data)
+	v := DealDocumentVersion{
+		FileName:    filepath.Base(f.Name),
+		ContentType: http.DetectContentType(f.Data),
+		Size:        len(f.Data),
+		Checksum:    hex.EncodeToString(sum[:]),
+		StorageKey:  "documents/" + id,
+	}
+	if err := s.files.Put(ctx, v.StorageKey, f.Data, v.ContentType); err != nil {
+		return DealDocumentVersion{}, err
+	}
+	return v, nil
+}
+
+// Create прикрепляет документ с первой версией файла.
+func (s *DealDocumentService) Create(ctx context.Context, doc DealDocument, userID int, f DealFile) (DealDocument, error) {
+	// внешнего ключа на сделку нет (она одна из трёх таблиц), поэтому проверяем здесь
+	if _, err := s.Parties(ctx, doc.EntityType, doc.EntityID); err != nil {
+		return DealDocument{}, err
+	}
+	if err := s.canAccess(ctx, userID, doc.EntityType, doc.EntityID); err != nil {
+		return DealDocument{}, err
+	}
+	v, err := s.store(ctx, f)
+	if err != nil {
+		return DealDocument{}, err
+	}
+	var saved DealDocument
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		doc.Version = 1
+		rows, err := s.documents.Insert(ctx, userID, doc)
+		if err != nil {
+			return err
+		}
+		saved = rows[0]
+		v.DocumentID, v.Version = saved.ID, 1
+		versions, err := s.versions.Insert(ctx, userID, v)
+		saved.Versions = versions
+		return err
+	})
+	if err != nil {
+		s.removeFiles(ctx, v.StorageKey)
+		return DealDocument{}, err
+	}
+	return saved, nil
+}
+
+// document — документ с проверкой доступа пользователя к его сделке.
+func (s *DealDocumentService) document(ctx context.Context, id, userID int) (DealDocument, error) {
+	doc, err := s.documents.Get(ctx, id)
+	if err != nil {
+		return doc, err
+	}
+	if err := s.canAccess(ctx, userID, doc.EntityType, doc.EntityID); err != nil {
+		return DealDocument{}, err
+	}
+	return doc, nil
+}
+
+// Versions — версии документа, последняя первой.
+func (s *DealDocumentService) Versions(ctx context.Context, docID int) ([]DealDocumentVersion, error) {
+	q := &ListQuery{
+		Conditions: []Condition{{Column: "document_id", Op: "=", Value: docID}},
+		SortColumn: "version",
+		SortDesc:   true,
+		NoTotal:    true,
+	}
+	versions, _, err := s.versions.List(ctx, q)
+	return versions, err
+}
+
+// Get — документ со всеми версиями.
+func (s *DealDocumentService) Get(ctx context.Context, id, userID int) (DealDocument, error) {
+	doc, err := s.document(ctx, id, userID)
+	if err != nil {
+		return doc, err
+	}
+	doc.Versions, err = s.Versions(ctx, id)
+	return doc, err
+}
+
+// AddVersion загружает новую версию файла; прежние версии остаются доступны.
+func (s *DealDocumentService) AddVersion(ctx context.Context, id, userID int, f DealFile) (DealDocumentVersion, error) {
+	if _, err := s.document(ctx, id, userID); err != nil {
+		return DealDocumentVersion{}, err
+	}
+	v, err := s.store(ctx, f)
+	if err != nil {
+		return DealDocumentVersion{}, err
+	}
+	var saved DealDocumentVersion
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		// Update блокирует документ: параллельные загрузки получат разные номера
+		doc, err := s.documents.Update(ctx, id, func(current DealDocument) (DealDocument, error) {
+			current.Version++
+			return current, nil
+		})
+		if err != nil {
+			return err
+		}
+		v.DocumentID, v.Version = id, doc.Version
+		rows, err := s.versions.Insert(ctx, userID, v)
+		if err != nil {
+			return err
+		}
+		saved = rows[0]
+		return nil
+	})
+	if err != nil {
+		s.removeFiles(ctx, v.StorageKey)
+		return DealDocumentVersion{}, err
+	}
+	return saved, nil
+}
+
+// Remove удаляет документ со всеми версиями. Удалить может загрузивший его или администратор.
+func (s *DealDocumentService) Remove(ctx context.Context, id, userID int) error {
+	var keys []string
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.document(ctx, id, userID); err != nil {
+			return err
+		}
+		versions, err := s.Versions(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			if err := s.versions.Delete(ctx, v.ID, nil); err != nil {
+				return err
+			}
+			keys = append(keys, v.StorageKey)
+		}
+		return s.documents.Delete(ctx, id, func(current DealDocument) error {
+			if role, _ := roleFrom(ctx); role != store.RoleAdmin && current.OwnerID != userID {
+				return newStatusError(http.StatusForbidden, "only the uploader or an admin can delete a document")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.removeFiles(ctx, keys...)
+	return nil
+}
+
+func (s *DealDocumentService) removeFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := s.files.Delete(ctx, key); err != nil {
+			log.Printf("document storage: delete %s: %v", key, err)
+		}
+	}
+}
+
+// Link выдаёт подписанную ссылку на версию документа (0 — последняя).
+func (s *DealDocumentService) Link(ctx context.Context, id, userID, version int, now time.Time) (DownloadLink, error) {
+	doc, err := s.document(ctx, id, userID)
+	if err != nil {
+		return DownloadLink{}, err
+	}
+	if version == 0 {
+		version = doc.Version
+	}
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "document_id", Op: "=", Value: id},
+			{Column: "version", Op: "=", Value: version},
+		},
+		Limit:   1,
+		NoTotal: true,
+	}
+	versions, _, err := s.versions.List(ctx, q)
+	if err != nil {
+		return DownloadLink{}, err
+	}
+	if len(versions) == 0 {
+		return DownloadLink{}, ErrNotFound
+	}
+	expires := now.Add(downloadTTL).Truncate(time.Second)
+	return DownloadLink{
+		URL:       signDownload(versions[0].ID, expires),
+		Version:   version,
+		ExpiresAt: expires,
+	}, nil
+}
+
+// signDownload — путь скачивания версии с временем истечения и подписью HMAC-SHA256.
+func signDownload(versionID int, expires time.Time) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("signature", downloadSignature(versionID, expires.Unix()))
+	return fmt.Sprintf("/documents/files/%d?%s", versionID, q.Encode())
+}
+
+func downloadSignature(versionID int, expires int64) string {
+	mac := hmac.New(sha256.New, signingSecret("DOWNLOAD_SECRET"))
+	fmt.Fprintf(mac, "%d.%d", versionID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyDownload проверяет подпись и срок ссылки.
+func verifyDownload(versionID int, expiresParam, signature string, now time.Time) error {
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(downloadSignature(versionID, expires))) {
+		return newStatusError(http.StatusForbidden, "invalid download link")
+	}
+	if now.Unix() > expires {
+		return newStatusError(http.StatusForbidden, "download link has expired")
+	}
+	return nil
+}
+
+// readUpload читает файл из поля field multipart-формы; при ошибке сам отвечает клиенту.
+func readUpload(w http.ResponseWriter, r *http.Request, field string, limit int64) (DealFile, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, limit+1<<20)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return DealFile{}, false
+		}
+		http.Error(w, "Expected multipart/form-data with a "+field+" field", http.StatusBadRequest)
+		return DealFile{}, false
+	}
+	f, fh, err := r.FormFile(field)
+	if err != nil {
+		http.Error(w, field+" is required", http.StatusBadRequest)
+		return DealFile{}, false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		writeRepoError(w, "ReadUpload", err)
+		return DealFile{}, false
+	}
+	return DealFile{Name: fh.Filename, Data: data}, true
+}
+
+// CreateHandler — POST /documents, multipart/form-data: entity_type, entity_id, category,
+// title (по умолчанию — имя файла) и file.
+func (s *DealDocumentService) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f, ok := readUpload(w, r, "file", maxDealDocumentSize)
+	if !ok {
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	entityID, _ := strconv.Atoi(r.FormValue("entity_id"))
+	doc := DealDocument{
+		EntityType: r.FormValue("entity_type"),
+		EntityID:   entityID,
+		Category:   r.FormValue("category"),
+		Title:      strings.TrimSpace(r.FormValue("title")),
+	}
+	if doc.Title == "" {
+		doc.Title = filepath.Base(f.Name)
+	}
+	if err := doc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	saved, err := s.Create(requestContext(r), doc, userID, f)
+	if err != nil {
+		writeRepoError(w, "CreateDealDocument", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// List — GET /documents. Администраторы фильтруют как угодно, остальные
+// обязаны указать сделку: ?entity_type=sale&entity_id=5.
+func (s *DealDocumentService) List(w http.ResponseWriter, r *http.Request) {
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	lq, err := parseListQuery(DealDocument{}, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if role, _ := roleFrom(r.Context()); role != store.RoleAdmin {
+		entityID, err := strconv.Atoi(r.URL.Query().Get("entity_id"))
+		if err != nil {
+			http.Error(w, "entity_type and entity_id are required", http.StatusBadRequest)
+			return
+		}
+		if err := s.canAccess(r.Context(), userID, r.URL.Query().Get("entity_type"), entityID); err != nil {
+			writeRepoError(w, "DealDocuments", err)
+			return
+		}
+	}
+	writeList(w, r, lq, s.documents.List)
+}
+
+// documentParams — {id} и пользователь для маршрутов /documents/{id}/...
+func documentParams(w http.ResponseWriter, r *http.Request) (id, userID int, ok bool) {
+	if id, ok = urlID(w, r); !ok {
+		return
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return id, 0, false
+	}
+	return id, userID, true
+}
+
+// GetHandler — GET /documents/{id}: документ со списком версий.
+func (s *DealDocumentService) GetHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := documentParams(w, r)
+	if !ok {
+		return
+	}
+	doc, err := s.Get(r.Context(), id, userID)
+	if err != nil {
+		writeRepoError(w, "GetDealDocument", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// VersionHandler — POST /documents/{id}/versions, multipart/form-data с полем file.
+func (s *DealDocumentService) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := documentParams(w, r)
+	if !ok {
+		return
+	}
+	f, ok := readUpload(w, r, "file", maxDealDocumentSize)
+	if !ok {
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	v, err := s.AddVersion(requestContext(r), id, userID, f)
+	if err != nil {
+		writeRepoError(w, "AddDocumentVersion", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// LinkHandler — GET /documents/{id}/link?version=N: ссылка на скачивание на downloadTTL.
+func (s *DealDocumentService) LinkHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := documentParams(w, r)
+	if !ok {
+		return
+	}
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "version must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		version = n
+	}
+	link, err := s.Link(r.Context(), id, userID, version, time.Now())
+	if err != nil {
+		writeRepoError(w, "DocumentLink", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, link)
+}
+
+// DeleteHandler — DELETE /documents/{id}.
+func (s *DealDocumentService) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := documentParams(w, r)
+	if !ok {
+		return
+	}
+	if err := s.Remove(requestContext(r), id, userID); err != nil {
+		writeRepoError(w, "DeleteDealDocument", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Операция завершилась успешно!"})
+}
+
+// Download — GET /documents/files/{versionID}?expires=...&signature=...; токен не нужен,
+// права проверены при выдаче ссылки.
+func (s *DealDocumentService) Download(w http.ResponseWriter, r *http.Request) {
+	versionID, err := strconv.Atoi(chi.URLParam(r, "versionID"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	if err := verifyDownload(versionID, q.Get("expires"), q.Get("signature"), time.Now()); err != nil {
+		writeRepoError(w, "DownloadDocument", err)
+		return
+	}
+	v, err := s.versions.Get(r.Context(), versionID)
+	if err != nil {
+		writeRepoError(w, "DownloadDocument", err)
+		return
+	}
+	f, err := s.files.Open(r.Context(), v.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		writeRepoError(w, "DownloadDocument", err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", v.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": v.FileName}))
+	w.Header().Set("Content-Length", strconv.Itoa(v.Size))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Checksum-SHA256", v.Checksum)
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
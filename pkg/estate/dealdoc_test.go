@@ -0,0 +1,35 @@
+package estate
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyDownload(t *testing.T) {
+	t.Setenv("DOWNLOAD_SECRET", "test-secret")
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Minute).Unix()
+	valid := downloadSignature(7, expires)
+	tests := []struct {
+		name      string
+		versionID int
+		expires   string
+		signature string
+		now       time.Time
+		wantErr   bool
+	}{
+		{"valid", 7, strconv.FormatInt(expires, 10), valid, now, false},
+		{"last second", 7, strconv.FormatInt(expires, 10), valid, time.Unix(expires, 0), false},
+		{"expired", 7, strconv.FormatInt(expires, 10), valid, time.Unix(expires+1, 0), true},
+		{"other version", 8, strconv.FormatInt(expires, 10), valid, now, true},
+		{"extended expiry", 7, strconv.FormatInt(expires+3600, 10), valid, now, true},
+		{"bad expiry", 7, "tomorrow", valid, now, true},
+		{"no signature", 7, strconv.FormatInt(expires, 10), "", now, true},
+	}
+	for _, tt := range tests {
+		if err := verifyDownload(tt.versionID, tt.expires, tt.signature, tt.now); (err != nil) != tt.wantErr {
+			t.Errorf("%s: verifyDownload = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package estate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"example-app/pkg/pdf"
+	"example-app/pkg/store"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Виды документов по продаже.
+const (
+	DocumentContract = "contract"
+	DocumentReceipt  = "receipt"
+)
+
+// DocumentTemplate — шаблон документа (text/template), редактируется администратором.
+// Разметка результата построчная: строка с "# " — заголовок, пустая строка — отступ,
+// остальные строки — абзацы. Данные шаблона — DocumentData.
+type DocumentTemplate struct {
+	ID        int       `json:"id" db:"id"`
+	Kind      string    `json:"kind" db:"kind"`
+	Title     string    `json:"title" db:"title"`
+	Body      string    `json:"body" db:"body"`
+	OwnerID   int       `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"-" db:"updated_at"`
+}
+
+func (t DocumentTemplate) GetNameTable() string {
+	return "document_templates"
+}
+func (t DocumentTemplate) GetNameColumns() string {
+	return "kind, title, body"
+}
+func (t DocumentTemplate) GetPlaceholder() string {
+	return "$1, $2, $3"
+}
+func (t DocumentTemplate) GetValues() []interface{} {
+	return []interface{}{
+		t.Kind, t.Title, t.Body,
+	}
+}
+func (t DocumentTemplate) GetUpdatedAt() time.Time {
+	return t.UpdatedAt
+}
+func (t DocumentTemplate) Validate() error {
+	if t.Kind != DocumentContract && t.Kind != DocumentReceipt {
+		return fmt.Errorf("kind must be %s or %s", DocumentContract, DocumentReceipt)
+	}
+	if strings.TrimSpace(t.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	// ошибку в шаблоне лучше показать при сохранении, а не при выдаче документа
+	tmpl, err := t.parse()
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(io.Discard, DocumentData{}); err != nil {
+		return fmt.Errorf("body: %v", err)
+	}
+	return nil
+}
+func (t DocumentTemplate) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"kind": {Column: "kind", Op: "=", Kind: KindString},
+	}
+}
+func (t DocumentTemplate) GetSortColumns() []string {
+	return []string{"id", "kind"}
+}
+
+func (t DocumentTemplate) parse() (*template.Template, error) {
+	tmpl, err := template.New(t.Kind).Funcs(documentFuncs).Option("missingkey=error").Parse(t.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %v", err)
+	}
+	return tmpl, nil
+}
+
+var documentFuncs = template.FuncMap{
+	"money": formatMoney,
+	"date":  func(t time.Time) string { return t.Format("02.01.2006") },
+}
+
+// formatMoney — сумма с пробелами между разрядами и копейками: 1 250 000.00.
+func formatMoney(v Amount) string {
+	s := v.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac := s[:len(s)-3], s[len(s)-3:]
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String() + frac
+}
+
+// defaultTemplates действуют, пока администратор не сохранил свой шаблон того же вида.
+var defaultTemplates = map[string]DocumentTemplate{
+	DocumentContract: {
+		Kind:  DocumentContract,
+		Title: "Договор купли-продажи",
+		Body: `# Договор купли-продажи № {{.Number}}
+
+Дата: {{date .Issued}}
+
+Продавец: {{.Seller.Name}} ({{.Seller.Email}}).
+Покупатель: {{.Buyer.Name}} ({{.Buyer.Email}}).
+
+1. Продавец передаёт в собственность покупателя объект недвижимости: {{.Property.Address}}{{if .Property.Type}}, тип: {{.Property.Type}}{{end}}.
+2. Цена объекта составляет {{money .Sale.FinalPrice}} {{.Sale.Currency}}. Дата продажи: {{date .Sale.SaleDate}}.
+3. Право собственности переходит к покупателю с момента регистрации продажи.
+
+Сделку оформил агент: {{.Agent.Name}} ({{.Agent.Email}}).
+
+Продавец: ____________________    Покупатель: ____________________`,
+	},
+	DocumentReceipt: {
+		Kind:  DocumentReceipt,
+		Title: "Расписка в получении денежных средств",
+		Body: `# Расписка № {{.Number}}
+
+Дата: {{date .Issued}}
+
+Я, {{.Seller.Name}}, получил(а) от {{.Buyer.Name}} денежные средства в размере {{money .Sale.FinalPrice}} {{.Sale.Currency}} в счёт оплаты объекта недвижимости по адресу: {{.Property.Address}}.
+
+Претензий по расчётам не имею.
+
+Подпись: ____________________`,
+	},
+}
+
+// DocumentData — данные, доступные в шаблоне.
+type DocumentData struct {
+	Number   string
+	Issued   time.Time
+	Sale     Sale
+	Property Property
+	Buyer    store.User
+	Seller   store.User
+	Agent    store.User
+}
+
+// SaleDocument — выданный документ. Содержимое не меняется после выдачи,
+// checksum (sha256) позволяет подтвердить, что именно было выдано.
+type SaleDocument struct {
+	ID         int       `json:"id" db:"id"`
+	SaleID     int       `json:"sale_id" db:"sale_id"`
+	Kind       string    `json:"kind" db:"kind"`
+	TemplateID *int      `json:"template_id" db:"template_id"`
+	Checksum   string    `json:"checksum" db:"checksum"`
+	Size       int       `json:"size" db:"size"`
+	Content    []byte    `json:"-" db:"content"`
+	OwnerID    int       `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+func (d SaleDocument) GetNameTable() string {
+	return "sale_documents"
+}
+func (d SaleDocument) GetNameColumns() string {
+	return "sale_id, kind, template_id, checksum, size, content"
+}
+func (d SaleDocument) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6"
+}
+func (d SaleDocument) GetValues() []interface{} {
+	return []interface{}{
+		d.SaleID, d.Kind, d.TemplateID, d.Checksum, d.Size, d.Content,
+	}
+}
+func (d SaleDocument) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"sale_id":  {Column: "sale_id", Op: "=", Kind: KindInt},
+		"kind":     {Column: "kind", Op: "=", Kind: KindString},
+		"checksum": {Column: "checksum", Op: "=", Kind: KindString},
+	}
+}
+func (d SaleDocument) GetSortColumns() []string {
+	return []string{"id", "created_at"}
+}
+
+// DocumentService выдаёт договор и расписку по продаже. Документ генерируется
+// один раз — при первом запросе — и дальше отдаётся из хранилища без изменений.
+type DocumentService struct {
+	tx          Transactor
+	templates   Repository[DocumentTemplate]
+	documents   Repository[SaleDocument]
+	sales       Repository[Sale]
+	properties  Repository[Property]
+	transitions Repository[PropertyTransition]
+	users       Repository[User]
+}
+
+func NewDocumentService(repos *Repositories) *DocumentService {
+	return &DocumentService{
+		tx:          repos.Tx,
+		templates:   repos.Templates,
+		documents:   repos.Documents,
+		sales:       repos.Sales,
+		properties:  repos.Properties,
+		transitions: repos.Transitions,
+		users:       repos.Users,
+	}
+}
+
+// Issue возвращает документ вида kind по продаже, при первом запросе генерируя его.
+func (s *DocumentService) Issue(ctx context.Context, data DocumentData, kind string) (SaleDocument, error) {
+	var doc SaleDocument
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		issued, ok, err := s.find(ctx, data.Sale.ID, kind)
+		if err != nil || ok {
+			doc = issued
+			return err
+		}
+		tmpl, err := s.template(ctx, kind)
+		if err != nil {
+			return err
+		}
+		content, err := render(tmpl, data)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		next := SaleDocument{
+			SaleID:   data.Sale.ID,
+			Kind:     kind,
+			Checksum: hex.EncodeToString(sum[:]),
+			Size:     len(content),
+			Content:  content,
+		}
+		if tmpl.ID != 0 {
+			next.TemplateID = &tmpl.ID
+		}
+		rows, err := s.documents.Insert(ctx, data.Sale.OwnerID, next)
+		if err != nil {
+			return err
+		}
+		doc = rows[0]
+		return nil
+	})
+	if errors.Is(err, ErrConstraint) {
+		// тот же документ параллельно выдал другой запрос — отдаём его
+		if issued, ok, ferr := s.find(ctx, data.Sale.ID, kind); ferr == nil && ok {
+			return issued, nil
+		}
+	}
+	return doc, err
+}
+
+func (s *DocumentService) find(ctx context.Context, saleID int, kind string) (SaleDocument, bool, error) {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "sale_id", Op: "=", Value: saleID},
+			{Column: "kind", Op: "=", Value: kind},
+		},
+		Limit:   1,
+		NoTotal: true,
+	}
+	docs, _, err := s.documents.List(ctx, q)
+	if err != nil || len(docs) == 0 {
+		return SaleDocument{}, false, err
+	}
+	return docs[0], true, nil
+}
+
+// template — сохранённый шаблон вида kind или встроенный.
+func (s *DocumentService) template(ctx context.Context, kind string) (DocumentTemplate, error) {
+	q := &ListQuery{Conditions: []Condition{{Column: "kind", Op: "=", Value: kind}}, Limit: 1, NoTotal: true}
+	tmpls, _, err := s.templates.List(ctx, q)
+	if err != nil {
+		return DocumentTemplate{}, err
+	}
+	if len(tmpls) > 0 {
+		return tmpls[0], nil
+	}
+	return defaultTemplates[kind], nil
+}
+
+func render(t DocumentTemplate, data DocumentData) ([]byte, error) {
+	tmpl, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, data); err != nil {
+		return nil, newStatusError(http.StatusUnprocessableEntity, "template %s: %v", t.Kind, err)
+	}
+	doc := pdf.New(t.Title)
+	for _, line := range strings.Split(text.String(), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			doc.Space()
+		case strings.HasPrefix(line, "# "):
+			doc.Heading(strings.TrimPrefix(line, "# "))
+		default:
+			doc.Paragraph(line)
+		}
+	}
+	return doc.Bytes(), nil
+}
+
+// Data собирает данные для шаблона. Продавец — владелец объекта в момент перехода sell;
+// удалённые объект и пользователи не мешают выдаче, соответствующие поля остаются пустыми.
+func (s *DocumentService) Data(ctx context.Context, saleID int) (DocumentData, error) {
+	sale, err := s.sales.Get(ctx, saleID)
+	if err != nil {
+		return DocumentData{}, err
+	}
+	data := DocumentData{
+		Number:   fmt.Sprintf("%06d", sale.ID),
+		Issued:   time.Now(),
+		Sale:     sale,
+		Property: Property{ID: sale.PropertyID},
+	}
+	if p, err := s.properties.Get(ctx, sale.PropertyID); err == nil {
+		data.Property = p
+	} else if !errors.Is(err, ErrNotFound) {
+		return DocumentData{}, err
+	}
+	sellerID, err := saleSeller(ctx, s.transitions, sale)
+	if err != nil {
+		return DocumentData{}, err
+	}
+	for _, u := range []struct {
+		id   int
+		into *store.User
+	}{{sale.BuyerID, &data.Buyer}, {sellerID, &data.Seller}, {sale.OwnerID, &data.Agent}} {
+		user, err := s.users.Get(ctx, u.id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return DocumentData{}, err
+		}
+		*u.into = user.User
+		u.into.ID = u.id
+	}
+	return data, nil
+}
+
+// saleSeller — продавец по продаже: владелец объекта в момент перехода sell.
+// Если перехода нет (продажа внесена вручную), продавцом считается агент.
+func saleSeller(ctx context.Context, transitions Repository[PropertyTransition], sale Sale) (int, error) {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "property_id", Op: "=", Value: sale.PropertyID},
+			{Column: "event", Op: "=", Value: EventSell},
+		},
+		SortColumn: "id",
+		SortDesc:   true,
+		Limit:      1,
+		NoTotal:    true,
+	}
+	sold, _, err := transitions.List(ctx, q)
+	if err != nil || len(sold) == 0 {
+		return sale.OwnerID, err
+	}
+	return sold[0].OwnerID, nil
+}
+
+// saleParty — документы по продаже видят администратор, агент, покупатель и продавец.
+func saleParty(r *http.Request, data DocumentData) bool {
+	if role, _ := roleFrom(r.Context()); role == store.RoleAdmin {
+		return true
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		return false
+	}
+	return containsInt([]int{data.Agent.ID, data.Buyer.ID, data.Seller.ID}, userID)
+}
+
+// partyData — данные продажи для обработчика; false, если ответ уже отправлен.
+func (s *DocumentService) partyData(w http.ResponseWriter, r *http.Request) (DocumentData, bool) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return DocumentData{}, false
+	}
+	data, err := s.Data(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, "SaleDocument", err)
+		return DocumentData{}, false
+	}
+	if !saleParty(r, data) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return DocumentData{}, false
+	}
+	return data, true
+}
+
+// PDFHandler — GET /sales/{id}/contract.pdf и /sales/{id}/receipt.pdf; контрольная
+// сумма выданного файла — в заголовке X-Checksum-SHA256.
+func (s *DocumentService) PDFHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, ok := s.partyData(w, r)
+		if !ok {
+			return
+		}
+		doc, err := s.Issue(requestContext(r), data, kind)
+		if err != nil {
+			writeRepoError(w, "IssueDocument", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-%d.pdf"`, kind, doc.SaleID))
+		w.Header().Set("X-Checksum-SHA256", doc.Checksum)
+		w.Header().Set("Content-Length", strconv.Itoa(len(doc.Content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(doc.Content)
+	}
+}
+
+// List — GET /sales/{id}/documents: выданные документы продажи без содержимого.
+func (s *DocumentService) List(w http.ResponseWriter, r *http.Request) {
+	data, ok := s.partyData(w, r)
+	if !ok {
+		return
+	}
+	lq, err := parseListQuery(SaleDocument{}, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lq.Conditions = append(lq.Conditions, Condition{Column: "sale_id", Op: "=", Value: data.Sale.ID})
+	writeList(w, r, lq, s.documents.List)
+}
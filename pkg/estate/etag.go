@@ -0,0 +1,88 @@
+package estate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errPreconditionFailed = errors.New("precondition failed")
+
+// Versioned реализуют типы с колонкой updated_at: ETag строится по ней,
+// а при изменении записи updated_at обновляется.
+type Versioned interface {
+	GetUpdatedAt() time.Time
+}
+
+// computeETag — версия записи: по updated_at, если он есть, иначе по содержимому.
+func computeETag(item interface{}) string {
+	h := sha256.New()
+	if v, ok := item.(Versioned); ok {
+		fmt.Fprintf(h, "%d", v.GetUpdatedAt().UnixNano())
+	} else {
+		json.NewEncoder(h).Encode(item)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
+
+// etagMatches проверяет список ETag из заголовка If-Match / If-None-Match.
+// weak — слабое сравнение (If-None-Match): префикс W/ игнорируется. Для If-Match
+// сравнение сильное (RFC 7232, 3.1), слабый валидатор не подходит.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch возвращает errPreconditionFailed, если клиент прислал If-Match,
+// а запись с тех пор изменилась.
+func checkIfMatch(r *http.Request, current interface{}) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+	if !etagMatches(header, computeETag(current), false) {
+		return errPreconditionFailed
+	}
+	return nil
+}
+
+// versionedSet добавляет обновление updated_at к SET для версионируемых типов.
+func versionedSet(item interface{}, set string) string {
+	if _, ok := item.(Versioned); ok {
+		return set + ", updated_at = now()"
+	}
+	return set
+}
+
+// writeJSONWithETag отдаёт ответ с ETag по телу и отвечает 304, если If-None-Match совпал.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
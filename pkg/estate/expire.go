@@ -0,0 +1,30 @@
+package estate
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Expirer закрывает записи, срок которых истёк к now, и возвращает их количество.
+type Expirer interface {
+	Expire(ctx context.Context, now time.Time) (int, error)
+}
+
+// RunExpirers периодически вызывает Expire у всех переданных сервисов
+// (брони, предложения); запускается в отдельной горутине.
+func RunExpirers(interval time.Duration, expirers ...Expirer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, e := range expirers {
+			n, err := e.Expire(context.Background(), time.Now())
+			if err != nil {
+				log.Printf("Expirer %T error: %v", e, err)
+			} else if n > 0 {
+				log.Printf("Expirer %T: закрыто %d записей", e, n)
+			}
+		}
+		<-ticker.C
+	}
+}
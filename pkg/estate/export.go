@@ -0,0 +1,312 @@
+package estate
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"example-app/pkg/xlsx"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// flushEvery — через сколько строк отдавать накопленное клиенту.
+const flushEvery = 500
+
+// exportField — колонка выгрузки: имя в заголовке (как в JSON) и путь к полю структуры.
+type exportField struct {
+	name  string
+	index []int
+}
+
+// Export выгружает записи в csv, xlsx или jsonl (параметр format) потоком,
+// не загружая всю выборку в память. Фильтры и сортировка — как у Read;
+// без явного limit выгружается всё.
+func (h *Handler[T]) Export(w http.ResponseWriter, r *http.Request) {
+	var item T
+	values := r.URL.Query()
+	format := values.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(w, "Unsupported format: "+format, http.StatusBadRequest)
+		return
+	}
+	lq, err := exportQuery(item, values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out := &exportResponse{ResponseWriter: w, start: func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, item.GetNameTable(), format))
+		w.WriteHeader(http.StatusOK)
+	}}
+	if err := writeExport(r.Context(), h.repo, lq, out, format); err != nil {
+		log.Println("Export error:", err)
+		// пока ничего не отправлено, ещё можно ответить ошибкой; иначе выгрузка просто обрывается
+		if !out.started {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+	// пустая выгрузка в jsonl не пишет ни байта, но заголовки всё равно нужны
+	out.begin()
+}
+
+// ExportTo пишет выгрузку в w без HTTP — для estatectl.
+func ExportTo[T Helper](ctx context.Context, repo Repository[T], w io.Writer, format string, values url.Values) error {
+	if _, ok := exportContentTypes[format]; !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	var item T
+	lq, err := exportQuery(item, values)
+	if err != nil {
+		return err
+	}
+	return writeExport(ctx, repo, lq, w, format)
+}
+
+var exportContentTypes = map[string]string{
+	"csv":   "text/csv; charset=utf-8",
+	"xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"jsonl": "application/x-ndjson",
+}
+
+// exportQuery — выборка списка без пагинации, если limit/offset не заданы явно.
+func exportQuery(item Helper, values url.Values) (*ListQuery, error) {
+	lq, err := parseListQuery(item, values)
+	if err != nil {
+		return nil, err
+	}
+	if !values.Has("limit") && !values.Has("offset") {
+		lq.Limit = 0
+	}
+	lq.NoTotal = true
+	return lq, nil
+}
+
+// exportResponse откладывает заголовки ответа до первой записанной строки,
+// чтобы ошибка выборки ещё могла стать ответом 500.
+type exportResponse struct {
+	http.ResponseWriter
+	start   func()
+	started bool
+}
+
+func (e *exportResponse) begin() {
+	if !e.started {
+		e.started = true
+		e.start()
+	}
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	e.begin()
+	return e.ResponseWriter.Write(p)
+}
+
+func (e *exportResponse) Flush() {
+	if f, ok := e.ResponseWriter.(http.Flusher); ok && e.started {
+		f.Flush()
+	}
+}
+
+type exportWriter[T Helper] interface {
+	WriteHeader(header []interface{}) error
+	WriteRow(item T) error
+	Flush() error
+	Close() error
+}
+
+func newExportWriter[T Helper](w io.Writer, format string, fields []exportField) (exportWriter[T], error) {
+	switch format {
+	case "csv":
+		return &csvExport[T]{w: csv.NewWriter(w), fields: fields}, nil
+	case "xlsx":
+		var item T
+		xw, err := xlsx.NewWriter(w, item.GetNameTable())
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxExport[T]{w: xw, fields: fields}, nil
+	default:
+		return &jsonlExport[T]{enc: json.NewEncoder(w)}, nil
+	}
+}
+
+// writeExport обходит выборку через Each; писатель формата создаётся на первой строке,
+// поэтому до неё в w ничего не пишется.
+func writeExport[T Helper](ctx context.Context, repo Repository[T], lq *ListQuery, w io.Writer, format string) error {
+	var item T
+	fields := exportFields(reflect.TypeOf(item))
+	header := make([]interface{}, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+
+	var out exportWriter[T]
+	open := func() error {
+		var err error
+		if out, err = newExportWriter[T](w, format, fields); err != nil {
+			return err
+		}
+		return out.WriteHeader(header)
+	}
+	flusher, _ := w.(http.Flusher)
+	n := 0
+	err := repo.Each(ctx, lq, func(row T) error {
+		if out == nil {
+			if err := open(); err != nil {
+				return err
+			}
+		}
+		if err := out.WriteRow(row); err != nil {
+			return err
+		}
+		n++
+		if n%flushEvery == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		if err := open(); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// exportFields перечисляет поля структуры в порядке объявления, пропуская
+// скрытые из JSON и служебную колонку корзины.
+func exportFields(t reflect.Type) []exportField {
+	var fields []exportField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for _, sub := range exportFields(f.Type) {
+				sub.index = append([]int{i}, sub.index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if db := f.Tag.Get("db"); name == "-" || db == "" || db == "-" || db == "deleted_at" {
+			continue
+		}
+		fields = append(fields, exportField{name: name, index: []int{i}})
+	}
+	return fields
+}
+
+// exportCells достаёт значения полей строки в виде, пригодном для таблицы.
+func exportCells(item interface{}, fields []exportField) []interface{} {
+	v := reflect.ValueOf(item)
+	cells := make([]interface{}, len(fields))
+	for i, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		switch val := fv.Interface().(type) {
+		case time.Time:
+			cells[i] = val.Format(time.RFC3339)
+		case int:
+			cells[i] = val
+		case float64:
+			cells[i] = val
+		case Amount:
+			cells[i] = val.Float64()
+		default:
+			cells[i] = fmt.Sprint(val)
+		}
+	}
+	return cells
+}
+
+type csvExport[T Helper] struct {
+	w      *csv.Writer
+	fields []exportField
+}
+
+func (e *csvExport[T]) WriteHeader(header []interface{}) error {
+	return e.w.Write(toStrings(header))
+}
+
+func (e *csvExport[T]) WriteRow(item T) error {
+	return e.w.Write(toStrings(exportCells(item, e.fields)))
+}
+
+func (e *csvExport[T]) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport[T]) Close() error {
+	return e.Flush()
+}
+
+type xlsxExport[T Helper] struct {
+	w      *xlsx.Writer
+	fields []exportField
+}
+
+func (e *xlsxExport[T]) WriteHeader(header []interface{}) error {
+	return e.w.WriteRow(header)
+}
+
+func (e *xlsxExport[T]) WriteRow(item T) error {
+	return e.w.WriteRow(exportCells(item, e.fields))
+}
+
+func (e *xlsxExport[T]) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *xlsxExport[T]) Close() error {
+	return e.w.Close()
+}
+
+type jsonlExport[T Helper] struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlExport[T]) WriteHeader(header []interface{}) error { return nil }
+
+func (e *jsonlExport[T]) WriteRow(item T) error {
+	return e.enc.Encode(item)
+}
+
+func (e *jsonlExport[T]) Flush() error { return nil }
+
+func (e *jsonlExport[T]) Close() error { return nil }
+
+func toStrings(cells []interface{}) []string {
+	out := make([]string, len(cells))
+	for i, c := range cells {
+		if c != nil {
+			out[i] = fmt.Sprint(c)
+		}
+	}
+	return out
+}
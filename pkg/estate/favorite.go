@@ -0,0 +1,257 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/store"
+	"net/http"
+	"time"
+)
+
+// Favorite — объект в избранном пользователя. Цена и статус запоминаются в момент
+// добавления: по ним GET /favorites отмечает, что объект с тех пор изменился.
+type Favorite struct {
+	ID           int       `json:"id" db:"id"`
+	PropertyID   int       `json:"property_id" db:"property_id"`
+	SeenPrice    Amount    `json:"seen_price" db:"seen_price"`
+	SeenCurrency Currency  `json:"seen_currency" db:"seen_currency"`
+	SeenStatus   string    `json:"seen_status" db:"seen_status"`
+	OwnerID      int       `json:"owner_id" db:"owner_id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+
+	// заполняются при выдаче списка
+	Property      *Property `json:"property,omitempty" db:"-"`
+	PriceChanged  bool      `json:"price_changed" db:"-"`
+	StatusChanged bool      `json:"status_changed" db:"-"`
+	// Removed — объект удалён (в корзине), Property пуст.
+	Removed bool `json:"removed,omitempty" db:"-"`
+}
+
+func (f Favorite) GetNameTable() string {
+	return "favorites"
+}
+func (f Favorite) GetNameColumns() string {
+	return "property_id, seen_price, seen_currency, seen_status"
+}
+func (f Favorite) GetPlaceholder() string {
+	return "$1, $2, $3, $4"
+}
+func (f Favorite) GetValues() []interface{} {
+	return []interface{}{
+		f.PropertyID, f.SeenPrice, f.SeenCurrency, f.SeenStatus,
+	}
+}
+func (f Favorite) GetUpdatedAt() time.Time {
+	return f.UpdatedAt
+}
+func (f Favorite) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"property_id": {Column: "property_id", Op: "=", Kind: KindInt},
+	}
+}
+func (f Favorite) GetSortColumns() []string {
+	return []string{"id", "created_at", "updated_at"}
+}
+func (f Favorite) withConverted(c *Converter) Favorite {
+	if f.Property != nil {
+		p := f.Property.withConverted(c)
+		f.Property = &p
+	}
+	return f
+}
+
+// seen запоминает текущие цену и статус объекта.
+func (f Favorite) seen(p Property) Favorite {
+	f.PropertyID = p.ID
+	f.SeenPrice = p.Price
+	f.SeenCurrency = p.Currency
+	f.SeenStatus = p.Status
+	return f
+}
+
+// withProperty дополняет запись текущим состоянием объекта и отметками изменений.
+func (f Favorite) withProperty(p Property) Favorite {
+	f.Property = &p
+	f.PriceChanged = p.Price != f.SeenPrice || p.Currency.String() != f.SeenCurrency.String()
+	f.StatusChanged = p.Status != f.SeenStatus
+	return f
+}
+
+// FavoriteService — избранное: доступно любому пользователю, в том числе с ролью «пользователь».
+type FavoriteService struct {
+	tx         Transactor
+	favorites  Repository[Favorite]
+	properties Repository[Property]
+}
+
+func NewFavoriteService(repos *Repositories) *FavoriteService {
+	return &FavoriteService{
+		tx:         repos.Tx,
+		favorites:  repos.Favorites,
+		properties: repos.Properties,
+	}
+}
+
+func (s *FavoriteService) find(ctx context.Context, userID, propertyID int) (Favorite, bool, error) {
+	q := &ListQuery{
+		Conditions: []Condition{{Column: "property_id", Op: "=", Value: propertyID}},
+		Limit:      1,
+		NoTotal:    true,
+	}
+	favs, _, err := s.favorites.ListByOwner(ctx, userID, q)
+	if err != nil || len(favs) == 0 {
+		return Favorite{}, false, err
+	}
+	return favs[0], true, nil
+}
+
+// Add добавляет объект в избранное. Повторное добавление запоминает текущие цену
+// и статус заново — так пользователь отмечает изменения просмотренными.
+func (s *FavoriteService) Add(ctx context.Context, userID, propertyID int) (Favorite, bool, error) {
+	var (
+		fav     Favorite
+		created bool
+	)
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		p, err := s.properties.Get(ctx, propertyID)
+		if err != nil {
+			return err
+		}
+		existing, ok, err := s.find(ctx, userID, propertyID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fav, err = s.favorites.Update(ctx, existing.ID, func(current Favorite) (Favorite, error) {
+				return current.seen(p), nil
+			})
+		} else {
+			var rows []Favorite
+			rows, err = s.favorites.Insert(ctx, userID, Favorite{}.seen(p))
+			if err == nil {
+				fav, created = rows[0], true
+			}
+		}
+		if err == nil {
+			fav = fav.withProperty(p)
+		}
+		return err
+	})
+	if errors.Is(err, ErrConstraint) {
+		// параллельный запрос уже добавил объект
+		if existing, ok, ferr := s.find(ctx, userID, propertyID); ferr == nil && ok {
+			if p, perr := s.properties.Get(ctx, propertyID); perr == nil {
+				existing = existing.withProperty(p)
+			}
+			return existing, false, nil
+		}
+	}
+	return fav, created, err
+}
+
+// Remove убирает объект из избранного; ErrNotFound, если его там нет.
+func (s *FavoriteService) Remove(ctx context.Context, userID, propertyID int) error {
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		fav, ok, err := s.find(ctx, userID, propertyID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+		return s.favorites.Delete(ctx, fav.ID, nil)
+	})
+}
+
+// listMine — избранное пользователя с текущим состоянием объектов.
+func (s *FavoriteService) listMine(ctx context.Context, userID int, q *ListQuery) ([]Favorite, int, error) {
+	favs, total, err := s.favorites.ListByOwner(ctx, userID, q)
+	if err != nil || len(favs) == 0 {
+		return favs, total, err
+	}
+	// объекты страницы — одним запросом; объекта нет в выборке — он удалён
+	ids := make([]int, len(favs))
+	for i, f := range favs {
+		ids[i] = f.PropertyID
+	}
+	props, _, err := s.properties.List(ctx, &ListQuery{
+		Conditions: []Condition{{Column: "id", Op: OpIn, Value: ids}},
+		NoTotal:    true,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[int]Property, len(props))
+	for _, p := range props {
+		byID[p.ID] = p
+	}
+	for i, f := range favs {
+		if p, ok := byID[f.PropertyID]; ok {
+			favs[i] = f.withProperty(p)
+		} else {
+			favs[i].Removed = true
+		}
+	}
+	return favs, total, nil
+}
+
+// Mine — GET /favorites, как GetMyData: владелец всегда берётся из токена.
+func (s *FavoriteService) Mine(w http.ResponseWriter, r *http.Request) {
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	lq, err := parseListQuery(Favorite{}, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeList(w, r, lq, func(ctx context.Context, q *ListQuery) ([]Favorite, int, error) {
+		return s.listMine(ctx, userID, q)
+	})
+}
+
+// AddHandler — POST /properties/{id}/favorite: 201 при добавлении, 200 при повторном.
+func (s *FavoriteService) AddHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	fav, created, err := s.Add(requestContext(r), userID, id)
+	if err != nil {
+		writeRepoError(w, "AddFavorite", err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, fav)
+}
+
+// RemoveHandler — DELETE /properties/{id}/favorite.
+func (s *FavoriteService) RemoveHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := s.Remove(requestContext(r), userID, id); err != nil {
+		writeRepoError(w, "RemoveFavorite", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Операция завершилась успешно!"})
+}
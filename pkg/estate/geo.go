@@ -0,0 +1,137 @@
+package estate
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKm = 6371.0
+
+// GeoLocated реализуют типы, у которых есть координаты; для них доступны
+// поиск в радиусе (lat, lng, radius_km) и в прямоугольнике карты (bbox).
+type GeoLocated interface {
+	GetGeoColumns() (lat, lng string)
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         int             `json:"id"`
+	Geometry   GeoJSONGeometry `json:"geometry"`
+	Properties interface{}     `json:"properties"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// addGeoFilters добавляет условия «в радиусе N км от точки» и «внутри bbox».
+// Расстояние считается по формуле гаверсинусов, PostGIS не нужен.
+func (q *ListQuery) addGeoFilters(g GeoLocated, values url.Values) error {
+	latCol, lngCol := g.GetGeoColumns()
+
+	if values.Get("radius_km") != "" {
+		lat, err1 := strconv.ParseFloat(values.Get("lat"), 64)
+		lng, err2 := strconv.ParseFloat(values.Get("lng"), 64)
+		radius, err3 := strconv.ParseFloat(values.Get("radius_km"), 64)
+		if err1 != nil || err2 != nil || err3 != nil || radius <= 0 {
+			return fmt.Errorf("radius search requires numeric lat, lng and positive radius_km")
+		}
+		if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return fmt.Errorf("lat/lng out of range")
+		}
+		q.Radius = &Radius{LatColumn: latCol, LngColumn: lngCol, Lat: lat, Lng: lng, Km: radius}
+	}
+
+	if bbox := values.Get("bbox"); bbox != "" {
+		// порядок как в GeoJSON: minLng,minLat,maxLng,maxLat
+		parts := strings.Split(bbox, ",")
+		if len(parts) != 4 {
+			return fmt.Errorf("bbox must be minLng,minLat,maxLng,maxLat")
+		}
+		var box [4]float64
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return fmt.Errorf("bbox must be minLng,minLat,maxLng,maxLat")
+			}
+			box[i] = v
+		}
+		if box[0] > box[2] || box[1] > box[3] {
+			return fmt.Errorf("bbox min must not exceed max")
+		}
+		q.addCondition(lngCol, ">=", box[0])
+		q.addCondition(latCol, ">=", box[1])
+		q.addCondition(lngCol, "<=", box[2])
+		q.addCondition(latCol, "<=", box[3])
+	}
+	return nil
+}
+
+// ReadGeoJSON отдаёт записи с координатами как GeoJSON FeatureCollection для карты.
+// Принимает те же фильтры и пагинацию, что и Read.
+func (h *Handler[T]) ReadGeoJSON(w http.ResponseWriter, r *http.Request) {
+	var item T
+	g, ok := interface{}(item).(GeoLocated)
+	if !ok {
+		http.Error(w, "Resource has no coordinates", http.StatusBadRequest)
+		return
+	}
+	latCol, lngCol := g.GetGeoColumns()
+
+	lq, err := parseListQuery(item, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lq.NotNull = append(lq.NotNull, latCol, lngCol)
+	lq.NoTotal = true
+
+	result, _, err := h.repo.List(r.Context(), lq)
+	if err != nil {
+		log.Println("ReadGeoJSON List error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	fc := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, row := range result {
+		lat, ok1 := columnValue(row, latCol).(float64)
+		lng, ok2 := columnValue(row, lngCol).(float64)
+		if !ok1 || !ok2 {
+			continue
+		}
+		fc.Features = append(fc.Features, GeoJSONFeature{
+			Type: "Feature",
+			ID:   recordID(row),
+			Geometry: GeoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{lng, lat},
+			},
+			Properties: row,
+		})
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(fc)
+}
+
+// distanceKm — расстояние между точками по формуле гаверсинусов.
+func distanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Pow(math.Sin(dLng/2), 2)
+	return earthRadiusKm * 2 * math.Asin(math.Min(1, math.Sqrt(a)))
+}
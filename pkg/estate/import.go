@@ -0,0 +1,277 @@
+package estate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"example-app/pkg/store"
+	"example-app/pkg/xlsx"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxImportSize = 10 << 20
+
+// ImportRowError — ошибка в конкретной строке файла (нумерация как в таблице, заголовок — строка 1).
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	DryRun   bool             `json:"dry_run"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// Import загружает записи из CSV или XLSX. Колонки сопоставляются с полями по
+// заголовку (имя поля в JSON) или по параметру mapping: {"Адрес": "address"}.
+// Корректные строки вставляются одной транзакцией от имени вызывающего;
+// при dry_run=true только проверяются.
+func (h *Handler[T]) Import(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	data, format, err := readImportFile(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := ParseRows(data, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mapping := map[string]string{}
+	if m := r.FormValue("mapping"); m != "" {
+		if err := json.Unmarshal([]byte(m), &mapping); err != nil {
+			http.Error(w, "Invalid mapping", http.StatusBadRequest)
+			return
+		}
+	}
+
+	items, report, err := DecodeRows[T](rows, mapping)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report.DryRun = r.FormValue("dry_run") == "true"
+	if !report.DryRun && len(items) > 0 {
+		ctx := WithAuditAction(requestContext(r), AuditImport)
+		if _, err := h.repo.Insert(ctx, ownerID, items...); err != nil {
+			log.Println("Import Insert error:", err)
+			http.Error(w, "Import failed: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		report.Imported = len(items)
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// readImportFile достаёт файл из multipart-поля file или из тела запроса.
+// Формат — из параметра format, расширения файла или Content-Type.
+func readImportFile(r *http.Request) ([]byte, string, error) {
+	format := r.URL.Query().Get("format")
+	var src io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", fmt.Errorf("file field is required")
+		}
+		defer file.Close()
+		src = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	} else if format == "" && strings.Contains(r.Header.Get("Content-Type"), "spreadsheetml") {
+		format = "xlsx"
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read file: %v", err)
+	}
+	if format == "" {
+		format = "csv"
+	}
+	return data, format, nil
+}
+
+// ParseRows разбирает CSV или XLSX в строки таблицы.
+func ParseRows(data []byte, format string) ([][]string, error) {
+	switch format {
+	case "xlsx":
+		return xlsx.ReadRows(data)
+	case "csv":
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		cr := csv.NewReader(bytes.NewReader(data))
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		// Excel в русской локали сохраняет CSV через точку с запятой
+		firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+		if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+			cr.Comma = ';'
+		}
+		rows, err := cr.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %v", err)
+		}
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// DecodeRows превращает строки таблицы в записи T. Первая строка — заголовок.
+// Строки с ошибками попадают в отчёт и в результат не включаются.
+func DecodeRows[T Helper](rows [][]string, mapping map[string]string) ([]T, ImportReport, error) {
+	report := ImportReport{Errors: []ImportRowError{}}
+	if len(rows) == 0 {
+		return nil, report, fmt.Errorf("file is empty")
+	}
+
+	var item T
+	columns := importColumns(item)
+	header := map[int]string{}
+	for i, h := range rows[0] {
+		name := strings.ToLower(strings.TrimSpace(h))
+		if mapped, ok := mapping[strings.TrimSpace(h)]; ok {
+			name = mapped
+		}
+		if col, ok := columns[name]; ok {
+			header[i] = col
+		}
+	}
+	if len(header) == 0 {
+		return nil, report, fmt.Errorf("no known columns in header")
+	}
+
+	var items []T
+	for n, record := range rows[1:] {
+		if isBlankRecord(record) {
+			continue
+		}
+		report.Total++
+		rowItem, err := decodeRecord[T](header, record)
+		if err == nil {
+			if v, ok := interface{}(rowItem).(Validator); ok {
+				err = v.Validate()
+			}
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Row: n + 2, Error: err.Error()})
+			continue
+		}
+		items = append(items, rowItem)
+	}
+	return items, report, nil
+}
+
+// importColumns — JSON-имя поля -> колонка, только для колонок, которые пишет INSERT.
+func importColumns(item Helper) map[string]string {
+	names := jsonNamesByColumn(item)
+	out := map[string]string{}
+	for _, col := range strings.Split(item.GetNameColumns(), ", ") {
+		out[names[col]] = col
+	}
+	return out
+}
+
+func decodeRecord[T Helper](header map[int]string, record []string) (T, error) {
+	var item T
+	v := reflect.ValueOf(&item).Elem()
+	for i, col := range header {
+		if i >= len(record) {
+			continue
+		}
+		f := columnMapper.FieldByName(v, col)
+		if err := setFromString(f, strings.TrimSpace(record[i])); err != nil {
+			return item, fmt.Errorf("%s: %v", col, err)
+		}
+	}
+	return item, nil
+}
+
+// setFromString записывает текстовое значение ячейки в поле с учётом его типа.
+func setFromString(f reflect.Value, s string) error {
+	if f.Kind() == reflect.Ptr {
+		if s == "" {
+			f.Set(reflect.Zero(f.Type()))
+			return nil
+		}
+		p := reflect.New(f.Type().Elem())
+		if err := setFromString(p.Elem(), s); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+	if f.Type() == reflect.TypeOf(Amount(0)) {
+		if s == "" {
+			return nil
+		}
+		a, err := ParseAmount(strings.ReplaceAll(strings.ReplaceAll(s, " ", ""), ",", "."))
+		if err != nil {
+			return fmt.Errorf("invalid amount %q", s)
+		}
+		f.Set(reflect.ValueOf(a))
+		return nil
+	}
+	if f.Type() == reflect.TypeOf(time.Time{}) {
+		if s == "" {
+			return nil
+		}
+		t, err := parseFilterValue(KindTime, s)
+		if err != nil {
+			return fmt.Errorf("invalid date %q", s)
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if s == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		f.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			return nil
+		}
+		// допускаем десятичную запятую и пробелы-разделители разрядов
+		n, err := strconv.ParseFloat(strings.ReplaceAll(strings.ReplaceAll(s, " ", ""), ",", "."), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, c := range record {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,268 @@
+package estate
+
+import (
+	"context"
+	"example-app/pkg/store"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Статусы объекта: draft → available → reserved → under_contract → sold/withdrawn.
+const (
+	StatusDraft         = "draft"
+	StatusAvailable     = "available"
+	StatusReserved      = "reserved"
+	StatusUnderContract = "under_contract"
+	StatusSold          = "sold"
+	StatusWithdrawn     = "withdrawn"
+)
+
+// События жизненного цикла — {event} в POST /properties/{id}/transitions/{event}.
+const (
+	EventPublish        = "publish"
+	EventReserve        = "reserve"
+	EventRelease        = "release"
+	EventContract       = "contract"
+	EventCancelContract = "cancel_contract"
+	EventSell           = "sell"
+	EventWithdraw       = "withdraw"
+	EventRelist         = "relist"
+)
+
+// Transition — из каких статусов событие допустимо, куда ведёт и каким ролям доступно.
+// Событие без ролей выполняет только сам сервис: reserve и release — брони
+// (reservation.go), sell — оформление продажи.
+type Transition struct {
+	From  []string
+	To    string
+	Roles []int
+}
+
+var staffRoles = []int{store.RoleAdmin, store.RoleAgent}
+
+var transitions = map[string]Transition{
+	EventPublish:        {From: []string{StatusDraft}, To: StatusAvailable, Roles: staffRoles},
+	EventReserve:        {From: []string{StatusAvailable}, To: StatusReserved},
+	EventRelease:        {From: []string{StatusReserved}, To: StatusAvailable},
+	EventContract:       {From: []string{StatusAvailable, StatusReserved}, To: StatusUnderContract, Roles: staffRoles},
+	EventCancelContract: {From: []string{StatusUnderContract}, To: StatusAvailable, Roles: staffRoles},
+	EventSell:           {From: []string{StatusAvailable, StatusReserved, StatusUnderContract}, To: StatusSold},
+	EventWithdraw:       {From: []string{StatusDraft, StatusAvailable, StatusReserved}, To: StatusWithdrawn, Roles: staffRoles},
+	EventRelist:         {From: []string{StatusWithdrawn}, To: StatusDraft, Roles: []int{store.RoleAdmin}},
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, v int) bool {
+	for _, n := range list {
+		if n == v {
+			return true
+		}
+	}
+	return false
+}
+
+// PropertyTransition — запись журнала переходов; owner_id — владелец объекта на момент перехода.
+type PropertyTransition struct {
+	ID         int       `json:"id" db:"id"`
+	PropertyID int       `json:"property_id" db:"property_id"`
+	Event      string    `json:"event" db:"event"`
+	FromStatus string    `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	ActorID    *int      `json:"actor_id" db:"actor_id"`
+	IP         string    `json:"ip" db:"ip"`
+	OwnerID    int       `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+func (t PropertyTransition) GetNameTable() string {
+	return "property_transitions"
+}
+func (t PropertyTransition) GetNameColumns() string {
+	return "property_id, event, from_status, to_status, actor_id, ip"
+}
+func (t PropertyTransition) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6"
+}
+func (t PropertyTransition) GetValues() []interface{} {
+	return []interface{}{
+		t.PropertyID, t.Event, t.FromStatus, t.ToStatus, t.ActorID, t.IP,
+	}
+}
+func (t PropertyTransition) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"event":     {Column: "event", Op: "=", Kind: KindString},
+		"to_status": {Column: "to_status", Op: "=", Kind: KindString},
+		"actor_id":  {Column: "actor_id", Op: "=", Kind: KindInt},
+		"date_from": {Column: "created_at", Op: ">=", Kind: KindTime},
+		"date_to":   {Column: "created_at", Op: "<=", Kind: KindTime},
+	}
+}
+func (t PropertyTransition) GetSortColumns() []string {
+	return []string{"id", "created_at"}
+}
+
+// Lifecycle меняет статус объекта по таблице transitions и пишет журнал переходов
+// в той же транзакции.
+type Lifecycle struct {
+	tx           Transactor
+	properties   Repository[Property]
+	transitions  Repository[PropertyTransition]
+	offers       Repository[Offer]
+	reservations Repository[Reservation]
+}
+
+func NewLifecycle(repos *Repositories) *Lifecycle {
+	return &Lifecycle{
+		tx:           repos.Tx,
+		properties:   repos.Properties,
+		transitions:  repos.Transitions,
+		offers:       repos.Offers,
+		reservations: repos.Reservations,
+	}
+}
+
+// Fire выполняет событие от имени роли: 404 — неизвестное событие, 403 — событие
+// недоступно роли, ErrConflict — событие недопустимо из текущего статуса.
+func (l *Lifecycle) Fire(ctx context.Context, id int, event string, role int) (Property, error) {
+	t, ok := transitions[event]
+	if !ok {
+		return Property{}, newStatusError(http.StatusNotFound, "unknown event: %s", event)
+	}
+	if !containsInt(t.Roles, role) {
+		return Property{}, newStatusError(http.StatusForbidden, "event %s is not allowed for this role", event)
+	}
+	return l.fire(ctx, id, event, nil)
+}
+
+// fire — переход без проверки роли; check (если задан) дополняет проверку статуса.
+func (l *Lifecycle) fire(ctx context.Context, id int, event string, check func(current Property) error) (Property, error) {
+	t := transitions[event]
+	var updated Property
+	err := l.tx.InTx(ctx, func(ctx context.Context) error {
+		var from string
+		p, err := l.properties.SetColumn(ctx, id, "status", t.To, func(current Property) error {
+			if !containsString(t.From, current.Status) {
+				return fmt.Errorf("%w: cannot %s property in status %q", ErrConflict, event, current.Status)
+			}
+			from = current.Status
+			if check != nil {
+				return check(current)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		a := actorFrom(ctx)
+		_, err = l.transitions.Insert(ctx, p.OwnerID, PropertyTransition{
+			PropertyID: id,
+			Event:      event,
+			FromStatus: from,
+			ToStatus:   t.To,
+			ActorID:    a.UserID,
+			IP:         a.IP,
+		})
+		if err != nil {
+			return err
+		}
+		updated = p
+		if event == EventCancelContract {
+			return l.withdrawAccepted(ctx, id)
+		}
+		// объект ушёл из reserved не снятием брони (договор, продажа, снятие
+		// с продажи) — бронь больше ничего не держит
+		if from == StatusReserved && event != EventRelease {
+			return l.releaseReservation(ctx, id)
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// releaseReservation закрывает действующую бронь объекта.
+func (l *Lifecycle) releaseReservation(ctx context.Context, propertyID int) error {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "property_id", Op: "=", Value: propertyID},
+			{Column: "status", Op: "=", Value: ReservationActive},
+		},
+		NoTotal: true,
+	}
+	active, _, err := l.reservations.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, res := range active {
+		if _, err := l.reservations.SetColumn(ctx, res.ID, "status", ReservationReleased, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withdrawAccepted закрывает принятые предложения по объекту: сделка сорвалась,
+// и продать объект по ним уже нельзя (см. OfferService.StartSale).
+func (l *Lifecycle) withdrawAccepted(ctx context.Context, propertyID int) error {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "property_id", Op: "=", Value: propertyID},
+			{Column: "status", Op: "=", Value: OfferAccepted},
+		},
+		NoTotal: true,
+	}
+	accepted, _, err := l.offers.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, o := range accepted {
+		if _, err := l.offers.SetColumn(ctx, o.ID, "status", OfferWithdrawn, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transition — POST /properties/{id}/transitions/{event}; роль кладёт в контекст Access.
+func (l *Lifecycle) Transition(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	role, ok := roleFrom(r.Context())
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	p, err := l.Fire(requestContext(r), id, chi.URLParam(r, "event"), role)
+	if err != nil {
+		writeRepoError(w, "Transition", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// History — GET /properties/{id}/transitions: журнал переходов объекта.
+func (l *Lifecycle) History(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	lq, err := parseListQuery(PropertyTransition{}, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lq.Conditions = append(lq.Conditions, Condition{Column: "property_id", Op: "=", Value: id})
+	writeList(w, r, lq, l.transitions.List)
+}
@@ -0,0 +1,117 @@
+package estate
+
+import (
+	"context"
+	"errors"
+	"example-app/pkg/store"
+	"net/http"
+	"testing"
+)
+
+func TestLifecycleFire(t *testing.T) {
+	// события, которыми объект доводится до статуса перед проверкой
+	paths := map[string][]string{
+		StatusDraft:         nil,
+		StatusAvailable:     {EventPublish},
+		StatusReserved:      {EventPublish, EventReserve},
+		StatusUnderContract: {EventPublish, EventContract},
+		StatusSold:          {EventPublish, EventSell},
+		StatusWithdrawn:     {EventWithdraw},
+	}
+	tests := []struct {
+		from       string
+		event      string
+		role       int
+		want       string
+		wantStatus int // код statusError; 409 — ErrConflict
+	}{
+		{StatusDraft, EventPublish, store.RoleAgent, StatusAvailable, 0},
+		{StatusAvailable, EventContract, store.RoleAgent, StatusUnderContract, 0},
+		{StatusReserved, EventContract, store.RoleAdmin, StatusUnderContract, 0},
+		{StatusUnderContract, EventCancelContract, store.RoleAgent, StatusAvailable, 0},
+		{StatusReserved, EventWithdraw, store.RoleAgent, StatusWithdrawn, 0},
+		{StatusWithdrawn, EventRelist, store.RoleAdmin, StatusDraft, 0},
+		{StatusWithdrawn, EventRelist, store.RoleAgent, "", http.StatusForbidden},
+		{StatusDraft, EventPublish, store.RoleUser, "", http.StatusForbidden},
+		{StatusAvailable, EventReserve, store.RoleAdmin, "", http.StatusForbidden},
+		{StatusAvailable, EventSell, store.RoleAdmin, "", http.StatusForbidden},
+		{StatusDraft, "demolish", store.RoleAdmin, "", http.StatusNotFound},
+		{StatusDraft, EventContract, store.RoleAgent, "", http.StatusConflict},
+		{StatusSold, EventWithdraw, store.RoleAdmin, "", http.StatusConflict},
+		{StatusUnderContract, EventWithdraw, store.RoleAdmin, "", http.StatusConflict},
+		{StatusAvailable, EventPublish, store.RoleAgent, "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		repos := NewMemoryRepositories()
+		l := NewLifecycle(repos)
+		rows, err := repos.Properties.Insert(ctx, 1, Property{Address: "Rudaki 1", Type: "apartment", Currency: "USD"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := rows[0].ID
+		for _, event := range paths[tt.from] {
+			if _, err := l.fire(ctx, id, event, nil); err != nil {
+				t.Fatalf("%s → %s: %v", tt.from, event, err)
+			}
+		}
+
+		p, err := l.Fire(ctx, id, tt.event, tt.role)
+		var se *statusError
+		switch {
+		case tt.wantStatus == http.StatusConflict:
+			if !errors.Is(err, ErrConflict) {
+				t.Errorf("%s from %s: error %v, want ErrConflict", tt.event, tt.from, err)
+			}
+		case tt.wantStatus != 0:
+			if !errors.As(err, &se) || se.status != tt.wantStatus {
+				t.Errorf("%s from %s: error %v, want status %d", tt.event, tt.from, err, tt.wantStatus)
+			}
+		case err != nil:
+			t.Errorf("%s from %s: %v", tt.event, tt.from, err)
+		case p.Status != tt.want:
+			t.Errorf("%s from %s: status %q, want %q", tt.event, tt.from, p.Status, tt.want)
+		}
+		if tt.wantStatus != 0 {
+			if got, _ := repos.Properties.Get(ctx, id); got.Status != tt.from {
+				t.Errorf("%s from %s: status changed to %q on error", tt.event, tt.from, got.Status)
+			}
+		}
+	}
+}
+
+func TestLifecycleJournal(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	l := NewLifecycle(repos)
+	rows, err := repos.Properties.Insert(ctx, 1, Property{Address: "Rudaki 1", Type: "apartment", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rows[0].ID
+	for _, event := range []string{EventPublish, EventWithdraw} {
+		if _, err := l.Fire(ctx, id, event, store.RoleAgent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Fire(ctx, id, EventPublish, store.RoleAgent); !errors.Is(err, ErrConflict) {
+		t.Fatalf("publish from withdrawn: %v", err)
+	}
+	journal, _, err := repos.Transitions.List(ctx, &ListQuery{SortColumn: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PropertyTransition{
+		{PropertyID: id, Event: EventPublish, FromStatus: StatusDraft, ToStatus: StatusAvailable},
+		{PropertyID: id, Event: EventWithdraw, FromStatus: StatusAvailable, ToStatus: StatusWithdrawn},
+	}
+	if len(journal) != len(want) {
+		t.Fatalf("journal: %+v", journal)
+	}
+	for i, w := range want {
+		got := journal[i]
+		if got.PropertyID != w.PropertyID || got.Event != w.Event || got.FromStatus != w.FromStatus || got.ToStatus != w.ToStatus || got.OwnerID != 1 {
+			t.Errorf("journal[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,673 @@
+package estate
+
+import (
+	"context"
+	"example-app/pkg/store"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository — Repository в памяти процесса. Повторяет поведение Postgres:
+// id и время создания проставляются при вставке, updated_at — при изменении,
+// корзина и журнал изменений работают так же. Ограничения схемы не проверяются.
+type MemoryRepository[T Helper] struct {
+	mu     sync.RWMutex
+	rows   map[int]T
+	nextID int
+	audit  *MemoryRepository[AuditEntry]
+}
+
+// NewMemoryRepository создаёт пустое хранилище; изменения пишутся в audit, если он не nil.
+func NewMemoryRepository[T Helper](audit *MemoryRepository[AuditEntry]) *MemoryRepository[T] {
+	return &MemoryRepository[T]{rows: map[int]T{}, audit: audit}
+}
+
+func (m *MemoryRepository[T]) List(ctx context.Context, q *ListQuery) ([]T, int, error) {
+	rows, err := m.selectRows(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := -1
+	if !q.NoTotal {
+		total = len(rows)
+	}
+	return page(rows, q), total, nil
+}
+
+func (m *MemoryRepository[T]) ListByOwner(ctx context.Context, ownerID int, q *ListQuery) ([]T, int, error) {
+	return m.List(ctx, q.withOwner(ownerID))
+}
+
+func (m *MemoryRepository[T]) Each(ctx context.Context, q *ListQuery, fn func(T) error) error {
+	rows, err := m.selectRows(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, row := range page(rows, q) {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository[T]) Get(ctx context.Context, id int) (T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current(id)
+}
+
+// Lock — то же, что Get: MemoryTransactor и так выполняет транзакции по одной.
+// Транзакция в контексте проверяется так же, как в Postgres.
+func (m *MemoryRepository[T]) Lock(ctx context.Context, id int) (T, error) {
+	if ctx.Value(txKey) == nil {
+		var zero T
+		return zero, errNoTx
+	}
+	return m.Get(ctx, id)
+}
+
+func (m *MemoryRepository[T]) Insert(ctx context.Context, ownerID int, items ...T) ([]T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	action := auditAction(ctx, AuditCreate)
+	saved := make([]T, 0, len(items))
+	for _, it := range items {
+		row := m.add(it, ownerID)
+		m.record(ctx, action, recordID(row), nil, row)
+		saved = append(saved, row)
+	}
+	return saved, nil
+}
+
+func (m *MemoryRepository[T]) Update(ctx context.Context, id int, mutate func(current T) (T, error)) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := m.current(id)
+	if err != nil {
+		return current, err
+	}
+	next, err := mutate(current)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	updated := current
+	dst := reflect.ValueOf(&updated).Elem()
+	src := reflect.ValueOf(next)
+	for _, col := range strings.Split(next.GetNameColumns(), ", ") {
+		copyColumn(dst, src, col)
+	}
+	if _, ok := interface{}(updated).(Versioned); ok {
+		setColumn(&updated, "updated_at", time.Now())
+	}
+	m.rows[id] = updated
+	m.record(ctx, AuditUpdate, id, current, updated)
+	return updated, nil
+}
+
+func (m *MemoryRepository[T]) SetColumn(ctx context.Context, id int, column string, value interface{}, check func(current T) error) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := m.current(id)
+	if err != nil {
+		return current, err
+	}
+	if _, ok := columnField(reflect.ValueOf(current), column); !ok {
+		var zero T
+		return zero, fmt.Errorf("%s: unknown column %s", current.GetNameTable(), column)
+	}
+	if check != nil {
+		if err := check(current); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	updated := current
+	setColumn(&updated, column, value)
+	if _, ok := interface{}(updated).(Versioned); ok {
+		setColumn(&updated, "updated_at", time.Now())
+	}
+	m.rows[id] = updated
+	m.record(ctx, AuditUpdate, id, current, updated)
+	return updated, nil
+}
+
+func (m *MemoryRepository[T]) Delete(ctx context.Context, id int, check func(current T) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := m.current(id)
+	if err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(current); err != nil {
+			return err
+		}
+	}
+	if _, ok := interface{}(current).(SoftDeletable); ok {
+		deleted := current
+		now := time.Now()
+		setColumn(&deleted, "deleted_at", &now)
+		m.rows[id] = deleted
+	} else {
+		delete(m.rows, id)
+	}
+	m.record(ctx, AuditDelete, id, current, nil)
+	return nil
+}
+
+func (m *MemoryRepository[T]) Restore(ctx context.Context, id int) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row, ok := m.rows[id]
+	if _, soft := interface{}(row).(SoftDeletable); !soft {
+		return row, errNoTrash
+	}
+	if !ok || !isDeleted(row) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	setColumn(&row, "deleted_at", (*time.Time)(nil))
+	m.rows[id] = row
+	m.record(ctx, AuditRestore, id, nil, row)
+	return row, nil
+}
+
+func (m *MemoryRepository[T]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var item T
+	if _, ok := interface{}(item).(SoftDeletable); !ok {
+		return 0, errNoTrash
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, row := range m.rows {
+		if at := interface{}(row).(SoftDeletable).GetDeletedAt(); at != nil && at.Before(before) {
+			delete(m.rows, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Put сохраняет запись целиком, минуя журнал, — для подготовки данных, которые
+// в Postgres создаются в обход Repository (например, пользователи из pkg/store).
+// Нулевой id заменяется следующим свободным.
+func (m *MemoryRepository[T]) Put(item T) T {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := recordID(item)
+	if id == 0 {
+		m.nextID++
+		id = m.nextID
+		setColumn(&item, "id", id)
+	} else if id > m.nextID {
+		m.nextID = id
+	}
+	m.rows[id] = item
+	return item
+}
+
+// snapshot запоминает содержимое хранилища и возвращает функцию отката к нему.
+func (m *MemoryRepository[T]) snapshot() func() {
+	m.mu.RLock()
+	rows := make(map[int]T, len(m.rows))
+	for id, row := range m.rows {
+		rows[id] = row
+	}
+	nextID := m.nextID
+	m.mu.RUnlock()
+	return func() {
+		m.mu.Lock()
+		m.rows, m.nextID = rows, nextID
+		m.mu.Unlock()
+	}
+}
+
+// MemoryTransactor — Transactor для хранилищ в памяти. Транзакции выполняются
+// по одной; при ошибке все хранилища откатываются к снимку, сделанному перед fn.
+type MemoryTransactor struct {
+	mu    sync.Mutex
+	repos []interface{ snapshot() func() }
+}
+
+func NewMemoryTransactor(repos ...interface{ snapshot() func() }) *MemoryTransactor {
+	return &MemoryTransactor{repos: repos}
+}
+
+func (t *MemoryTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey) != nil {
+		return fn(ctx)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	restore := make([]func(), len(t.repos))
+	for i, r := range t.repos {
+		restore[i] = r.snapshot()
+	}
+	if err := fn(context.WithValue(ctx, txKey, t)); err != nil {
+		for _, undo := range restore {
+			undo()
+		}
+		return err
+	}
+	return nil
+}
+
+// current — запись не из корзины; вызывается под блокировкой.
+func (m *MemoryRepository[T]) current(id int) (T, error) {
+	row, ok := m.rows[id]
+	if !ok || isDeleted(row) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return row, nil
+}
+
+// add сохраняет запись как INSERT: только колонки из GetNameColumns плюс id,
+// owner_id и текущее время во всех незаполненных датах (как DEFAULT now()).
+func (m *MemoryRepository[T]) add(item T, ownerID int) T {
+	var row T
+	dst := reflect.ValueOf(&row).Elem()
+	src := reflect.ValueOf(item)
+	for _, col := range strings.Split(item.GetNameColumns(), ", ") {
+		copyColumn(dst, src, col)
+	}
+	if d, ok := interface{}(row).(defaulter[T]); ok {
+		row = d.withDefaults()
+	}
+	m.nextID++
+	setColumn(&row, "id", m.nextID)
+	setColumn(&row, "owner_id", ownerID)
+	now := time.Now()
+	for _, fi := range columnMapper.TypeMap(dst.Type()).Index {
+		f := dst.FieldByIndex(fi.Index)
+		if f.Type() == reflect.TypeOf(now) && f.Interface().(time.Time).IsZero() {
+			f.Set(reflect.ValueOf(now))
+		}
+	}
+	m.rows[m.nextID] = row
+	return row
+}
+
+// defaulter — типы с DEFAULT-значениями в схеме, которые INSERT не передаёт;
+// хранилище в памяти подставляет их само.
+type defaulter[T any] interface {
+	withDefaults() T
+}
+
+// record пишет журнал изменений; вызывается под блокировкой хранилища.
+func (m *MemoryRepository[T]) record(ctx context.Context, action string, id int, before, after interface{}) {
+	if m.audit == nil {
+		return
+	}
+	var item T
+	a := actorFrom(ctx)
+	entry, err := newAuditEntry(a.UserID, a.IP, action, item.GetNameTable(), id, before, after)
+	if err != nil {
+		return
+	}
+	m.audit.mu.Lock()
+	m.audit.add(entry, 0)
+	m.audit.mu.Unlock()
+}
+
+// selectRows — отфильтрованные и отсортированные записи без пагинации.
+func (m *MemoryRepository[T]) selectRows(ctx context.Context, q *ListQuery) ([]T, error) {
+	conv, err := amountConverter(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	rows := make([]T, 0, len(m.rows))
+	for _, row := range m.rows {
+		if matches(row, q, conv) {
+			rows = append(rows, row)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(rows, func(i, j int) bool {
+		c := compareRows(rows[i], rows[j], q.SortColumn)
+		if q.SortConverted {
+			c = compareNullLast(convertedValue(rows[i], q.SortColumn, conv), convertedValue(rows[j], q.SortColumn, conv))
+			if c == 0 {
+				c = recordID(rows[i]) - recordID(rows[j])
+			}
+		}
+		if q.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+	return rows, nil
+}
+
+// amountConverter — Converter в валюту q.Currency, если выборке нужен пересчёт сумм.
+// Postgres берёт курсы из exchange_rates в самом запросе, а здесь они приходят
+// из контекста (см. Currencies).
+func amountConverter(ctx context.Context, q *ListQuery) (*Converter, error) {
+	if !q.converts() {
+		return nil, nil
+	}
+	c, ok := ctx.Value(converterKey).(*Converter)
+	if !ok || c.To.String() != q.Currency.String() {
+		return nil, fmt.Errorf("no exchange rates for %s in context", q.Currency)
+	}
+	return c, nil
+}
+
+// convertedValue — сумма из колонки col в валюте conv.To; nil, если курса валюты записи нет.
+func convertedValue(row interface{}, col string, conv *Converter) interface{} {
+	a, ok := columnValue(row, col).(Amount)
+	if !ok {
+		return nil
+	}
+	from, _ := columnValue(row, "currency").(Currency)
+	v, ok := conv.Convert(a, from)
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+func page[T Helper](rows []T, q *ListQuery) []T {
+	if q.Offset >= len(rows) {
+		return []T{}
+	}
+	rows = rows[q.Offset:]
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+	return rows
+}
+
+func isDeleted(row interface{}) bool {
+	s, ok := row.(SoftDeletable)
+	return ok && s.GetDeletedAt() != nil
+}
+
+// matches проверяет запись на все условия ListQuery; conv нужен только условиям Converted.
+func matches(row interface{}, q *ListQuery, conv *Converter) bool {
+	if _, ok := row.(SoftDeletable); ok && isDeleted(row) != q.Trash {
+		return false
+	}
+	for _, c := range q.Conditions {
+		v := columnValue(row, c.Column)
+		if c.Converted {
+			v = convertedValue(row, c.Column, conv)
+		}
+		if v == nil {
+			return false
+		}
+		if c.Op == OpIn {
+			if !containsValue(c.Value, v) {
+				return false
+			}
+			continue
+		}
+		cmp, ok := compareValues(v, c.Value)
+		if !ok || !opHolds(c.Op, cmp) {
+			return false
+		}
+	}
+	for _, col := range q.NotNull {
+		if columnValue(row, col) == nil {
+			return false
+		}
+	}
+	if r := q.Radius; r != nil {
+		lat, ok1 := columnValue(row, r.LatColumn).(float64)
+		lng, ok2 := columnValue(row, r.LngColumn).(float64)
+		if !ok1 || !ok2 || distanceKm(r.Lat, r.Lng, lat, lng) > r.Km {
+			return false
+		}
+	}
+	if q.After != nil {
+		c := 0
+		if q.SortColumn != "id" {
+			var ok bool
+			if c, ok = compareValues(columnValue(row, q.SortColumn), q.After.Value); !ok {
+				return false
+			}
+		}
+		if c == 0 {
+			c = recordID(row) - q.After.ID
+		}
+		if q.SortDesc {
+			return c < 0
+		}
+		return c > 0
+	}
+	return true
+}
+
+// containsValue — v равно одному из элементов среза list.
+func containsValue(list, v interface{}) bool {
+	l := reflect.ValueOf(list)
+	if l.Kind() != reflect.Slice {
+		return false
+	}
+	for i := 0; i < l.Len(); i++ {
+		if cmp, ok := compareValues(v, l.Index(i).Interface()); ok && cmp == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func opHolds(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "<>", "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// compareRows сравнивает записи по колонке, затем по id.
+func compareRows(a, b interface{}, col string) int {
+	if col != "" && col != "id" {
+		if c := compareNullLast(columnValue(a, col), columnValue(b, col)); c != 0 {
+			return c
+		}
+	}
+	return recordID(a) - recordID(b)
+}
+
+// compareNullLast сравнивает значения колонок; NULL — после всех значений, как в Postgres.
+func compareNullLast(va, vb interface{}) int {
+	switch {
+	case va == nil && vb != nil:
+		return 1
+	case va != nil && vb == nil:
+		return -1
+	case va != nil && vb != nil:
+		if c, ok := compareValues(va, vb); ok {
+			return c
+		}
+	}
+	return 0
+}
+
+// columnValue — значение колонки с разыменованным указателем; nil для NULL и неизвестных колонок.
+func columnValue(row interface{}, col string) interface{} {
+	f, ok := columnField(reflect.ValueOf(row), col)
+	if !ok {
+		return nil
+	}
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return nil
+		}
+		f = f.Elem()
+	}
+	return f.Interface()
+}
+
+func setColumn[T any](row *T, col string, value interface{}) {
+	f, ok := columnField(reflect.ValueOf(row).Elem(), col)
+	if !ok {
+		return
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		f.Set(reflect.Zero(f.Type()))
+	case f.Kind() == reflect.Ptr && v.Kind() != reflect.Ptr:
+		// nullable-колонка: значение кладём по указателю, как это сделал бы Scan
+		p := reflect.New(f.Type().Elem())
+		p.Elem().Set(v.Convert(f.Type().Elem()))
+		f.Set(p)
+	default:
+		f.Set(v.Convert(f.Type()))
+	}
+}
+
+// copyColumn переносит значение колонки из src в dst (обе колонки известны по GetNameColumns).
+func copyColumn(dst, src reflect.Value, col string) {
+	to, ok := columnField(dst, col)
+	from, ok2 := columnField(src, col)
+	if ok && ok2 {
+		to.Set(from)
+	}
+}
+
+// columnField — поле структуры по колонке. В отличие от columnMapper.FieldByName
+// сообщает, что колонки нет, а не возвращает саму структуру.
+func columnField(v reflect.Value, col string) (reflect.Value, bool) {
+	v = reflect.Indirect(v)
+	fi, ok := columnMapper.TypeMap(v.Type()).Names[col]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return v.FieldByIndex(fi.Index), true
+}
+
+// compareValues сравнивает значение колонки с параметром выборки. Числа сравниваются
+// как float64; время может прийти строкой (из курсора) — тогда оно разбирается.
+func compareValues(a, b interface{}) (int, bool) {
+	// пустая валюта — базовая, как её и сохраняет Postgres
+	if c, ok := a.(Currency); ok {
+		a = c.String()
+	}
+	if c, ok := b.(Currency); ok {
+		b = c.String()
+	}
+	if t, ok := a.(time.Time); ok {
+		switch v := b.(type) {
+		case time.Time:
+			return t.Compare(v), true
+		case string:
+			parsed, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return 0, false
+			}
+			return t.Compare(parsed), true
+		}
+		return 0, false
+	}
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	if s, ok := a.(string); ok {
+		if v, ok := b.(string); ok {
+			return strings.Compare(s, v), true
+		}
+		return strings.Compare(s, fmt.Sprint(b)), true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case Amount:
+		return n.Float64(), true
+	}
+	return 0, false
+}
+
+// MemoryUsers — store.Users поверх хранилища пользователей из NewMemoryRepositories:
+// зарегистрированные через /register сразу видны проверкам ролей (Access).
+type MemoryUsers struct {
+	mu    sync.Mutex
+	users *MemoryRepository[User]
+}
+
+// NewMemoryUsers принимает только хранилища из NewMemoryRepositories.
+func NewMemoryUsers(repos *Repositories) *MemoryUsers {
+	users, ok := repos.Users.(*MemoryRepository[User])
+	if !ok {
+		panic("estate: NewMemoryUsers needs repositories from NewMemoryRepositories")
+	}
+	return &MemoryUsers{users: users}
+}
+
+func (m *MemoryUsers) UserByEmail(email string) (store.User, error) {
+	q := &ListQuery{Conditions: []Condition{{Column: "email", Op: "=", Value: email}}, Limit: 1, NoTotal: true}
+	users, _, err := m.users.List(context.Background(), q)
+	if err != nil {
+		return store.User{}, err
+	}
+	if len(users) == 0 {
+		return store.User{}, store.ErrUserNotFound
+	}
+	return users[0].User, nil
+}
+
+// CreateUser сохраняет пользователя целиком через Put: Insert копирует только
+// role_id, остальные колонки в Postgres заполняет pkg/store.
+func (m *MemoryUsers) CreateUser(name, email, password string, roleID int) (int, error) {
+	hash, err := store.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	// email уникален, как в таблице users
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.UserByEmail(email); err == nil {
+		return 0, fmt.Errorf("%w: email %s is taken", ErrConstraint, email)
+	}
+	now := time.Now()
+	row := m.users.Put(User{store.User{
+		Name:      name,
+		Email:     email,
+		Password:  hash,
+		RoleID:    roleID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}})
+	return row.ID, nil
+}
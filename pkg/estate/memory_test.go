@@ -0,0 +1,29 @@
+package estate
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryLockRequiresTx(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	rows, err := repos.Properties.Insert(ctx, 1, Property{Address: "Rudaki 1", Type: "apartment", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Properties.Lock(ctx, rows[0].ID); !errors.Is(err, errNoTx) {
+		t.Fatalf("Lock outside a transaction: %v, want errNoTx", err)
+	}
+	err = repos.Tx.InTx(ctx, func(ctx context.Context) error {
+		p, err := repos.Properties.Lock(ctx, rows[0].ID)
+		if err == nil && p.ID != rows[0].ID {
+			t.Errorf("Lock = %+v", p)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Lock in a transaction: %v", err)
+	}
+}
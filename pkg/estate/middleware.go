@@ -1,7 +1,9 @@
 package estate
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"example-app/pkg/store"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +14,36 @@ import (
 	"github.com/go-chi/jwtauth"
 )
 
+// ownerLookup возвращает owner_id записи по id.
+type ownerLookup func(ctx context.Context, id int) (int, error)
+
+// Access — проверки ролей и владения записью. Роль читается из хранилища
+// пользователей, владелец — из хранилища ресурса, зарегистрированного через RegisterOwner.
+type Access struct {
+	users  Repository[User]
+	owners map[string]ownerLookup
+}
+
+func NewAccess(users Repository[User]) *Access {
+	return &Access{users: users, owners: map[string]ownerLookup{}}
+}
+
+// RegisterOwner разрешает RequireAdminOrAgent проверять владельца записей T
+// на маршрутах вида /<таблица>/{id}.
+func RegisterOwner[T Helper](a *Access, repo Repository[T]) {
+	var item T
+	a.owners[item.GetNameTable()] = func(ctx context.Context, id int) (int, error) {
+		row, err := repo.Get(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if v, ok := columnValue(row, "owner_id").(int); ok {
+			return v, nil
+		}
+		return 0, nil
+	}
+}
+
 func tableFromPath(path string) (string, error) {
 	// нормализуем путь и берем первую часть: "/properties/123" -> "properties"
 	clean := strings.Trim(path, "/")
@@ -26,7 +58,22 @@ func tableFromPath(path string) (string, error) {
 	return table, nil
 }
 
-func RequireAdmin(next http.Handler) http.Handler {
+// roleOf — роль пользователя по email из токена.
+func (a *Access) roleOf(ctx context.Context, email string) (int, error) {
+	q := &ListQuery{Conditions: []Condition{{Column: "email", Op: "=", Value: email}}, Limit: 1, NoTotal: true}
+	users, _, err := a.users.List(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, ErrNotFound
+	}
+	return users[0].RoleID, nil
+}
+
+// LoadRole кладёт роль пользователя в контекст, ничего не запрещая: права
+// проверяет сам обработчик (например, стороны переговоров по предложению).
+func (a *Access) LoadRole(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, claims, _ := jwtauth.FromContext(r.Context())
 		email, ok := claims["sub"].(string)
@@ -34,10 +81,31 @@ func RequireAdmin(next http.Handler) http.Handler {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-		var roleID int
-		err := DB.Get(&roleID, "SELECT role_id FROM users WHERE email = $1", email)
+		roleID, err := a.roleOf(r.Context(), email)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			log.Println("LoadRole DB error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withRole(r.Context(), roleID)))
+	})
+}
+
+func (a *Access) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, _ := jwtauth.FromContext(r.Context())
+		email, ok := claims["sub"].(string)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		roleID, err := a.roleOf(r.Context(), email)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
 				http.Error(w, "User not found", http.StatusNotFound)
 				return
 			}
@@ -45,15 +113,15 @@ func RequireAdmin(next http.Handler) http.Handler {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		if roleID != 1 {
+		if roleID != store.RoleAdmin {
 			http.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(withRole(r.Context(), roleID)))
 	})
 }
 
-func RequireAdminOrAgent(next http.Handler) http.Handler {
+func (a *Access) RequireAdminOrAgent(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, claims, _ := jwtauth.FromContext(r.Context())
 		email, ok := claims["sub"].(string)
@@ -69,9 +137,9 @@ func RequireAdminOrAgent(next http.Handler) http.Handler {
 		}
 		userID := int(userIDFloat)
 
-		var roleID int
-		if err := DB.Get(&roleID, "SELECT role_id FROM users WHERE email = $1", email); err != nil {
-			if err == sql.ErrNoRows {
+		roleID, err := a.roleOf(r.Context(), email)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
 				http.Error(w, "User not found", http.StatusNotFound)
 				return
 			}
@@ -81,7 +149,7 @@ func RequireAdminOrAgent(next http.Handler) http.Handler {
 		}
 
 		// если роль не admin(1) и не agent(2) — запрет
-		if roleID != 1 && roleID != 2 {
+		if roleID != store.RoleAdmin && roleID != store.RoleAgent {
 			http.Error(w, "Agent access required", http.StatusForbidden)
 			return
 		}
@@ -95,15 +163,14 @@ func RequireAdminOrAgent(next http.Handler) http.Handler {
 				return
 			}
 			table, err := tableFromPath(r.URL.Path)
-			if err != nil {
+			lookup, ok := a.owners[table]
+			if err != nil || !ok {
 				http.Error(w, "Invalid resource", http.StatusBadRequest)
 				return
 			}
-			// безопасно составляем запрос — table уже whitelist'ирована
-			query := fmt.Sprintf("SELECT owner_id FROM %s WHERE id = $1", table)
-			var ownerID int
-			if err := DB.Get(&ownerID, query, id); err != nil {
-				if err == sql.ErrNoRows {
+			ownerID, err := lookup(r.Context(), id)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
 					http.Error(w, "Not Found", http.StatusNotFound)
 					return
 				}
@@ -112,12 +179,12 @@ func RequireAdminOrAgent(next http.Handler) http.Handler {
 				return
 			}
 			// если владелец есть и он не совпадает с текущим пользователем — запрет (за исключением админа)
-			if ownerID != 0 && ownerID != userID && roleID != 1 {
+			if ownerID != 0 && ownerID != userID && roleID != store.RoleAdmin {
 				http.Error(w, "Недостаточно прав доступа", http.StatusForbidden)
 				return
 			}
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(withRole(r.Context(), roleID)))
 	})
 }
@@ -0,0 +1,385 @@
+package estate
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BaseCurrency — валюта, к которой приведены курсы в exchange_rates, и валюта
+// денежных полей по умолчанию.
+const BaseCurrency = "USD"
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Currency — код валюты ISO 4217; пустой код означает базовую валюту и так
+// и сохраняется в базу и отдаётся в JSON.
+type Currency string
+
+func (c Currency) String() string {
+	if c == "" {
+		return BaseCurrency
+	}
+	return string(c)
+}
+
+func (c Currency) Validate() error {
+	if c != "" && !currencyCode.MatchString(string(c)) {
+		return fmt.Errorf("currency must be a three-letter ISO 4217 code")
+	}
+	return nil
+}
+
+func (c Currency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c Currency) Value() (driver.Value, error) {
+	return c.String(), nil
+}
+
+// Знаков после запятой у Amount (как NUMERIC(14, 2)) и у Rate (как NUMERIC(18, 8)).
+const (
+	amountPlaces = 2
+	ratePlaces   = 8
+)
+
+// Amount — денежная сумма в сотых долях: арифметика целочисленная, без ошибок округления
+// float64. В базе — NUMERIC(14, 2), в JSON — число (строка тоже принимается).
+type Amount int64
+
+// ParseAmount разбирает десятичную запись суммы; больше двух знаков после запятой — ошибка.
+func ParseAmount(s string) (Amount, error) {
+	n, err := parseFixed(s, amountPlaces)
+	return Amount(n), err
+}
+
+func (a Amount) String() string {
+	return formatFixed(int64(a), amountPlaces)
+}
+
+// Float64 — для сравнения с параметрами выборки и числовых ячеек выгрузки.
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+// Percent — rate процентов от суммы с округлением до сотых.
+func (a Amount) Percent(rate float64) Amount {
+	r := new(big.Rat).SetInt64(int64(a))
+	p, _ := new(big.Rat).SetString(fmt.Sprint(rate))
+	r.Mul(r, p)
+	r.Quo(r, big.NewRat(100, 1))
+	return Amount(roundRat(r))
+}
+
+// Convert пересчитывает сумму по курсам валют from и to (оба — к базовой валюте).
+func (a Amount) Convert(from, to Rate) Amount {
+	r := new(big.Rat).SetInt64(int64(a))
+	r.Mul(r, big.NewRat(int64(from), int64(to)))
+	return Amount(roundRat(r))
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalFixed(b, amountPlaces)
+	*a = Amount(n)
+	return err
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+func (a *Amount) Scan(src interface{}) error {
+	n, err := scanFixed(src, amountPlaces)
+	*a = Amount(n)
+	return err
+}
+
+// Rate — курс валюты: сколько единиц базовой валюты стоит одна её единица,
+// с точностью до 8 знаков.
+type Rate int64
+
+// baseRate — курс базовой валюты к самой себе.
+const baseRate Rate = 100000000
+
+func ParseRate(s string) (Rate, error) {
+	n, err := parseFixed(s, ratePlaces)
+	return Rate(n), err
+}
+
+func (r Rate) String() string {
+	return formatFixed(int64(r), ratePlaces)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalFixed(b, ratePlaces)
+	*r = Rate(n)
+	return err
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func (r *Rate) Scan(src interface{}) error {
+	n, err := scanFixed(src, ratePlaces)
+	*r = Rate(n)
+	return err
+}
+
+// parseFixed переводит десятичную запись (в том числе с экспонентой) в целое число
+// единиц 10^-places.
+func parseFixed(s string, places int) (int64, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%q: at most %d decimal places allowed", s, places)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	return r.Num().Int64(), nil
+}
+
+func formatFixed(n int64, places int) string {
+	sign := ""
+	u := uint64(n)
+	if n < 0 {
+		sign, u = "-", uint64(-n)
+	}
+	s := fmt.Sprintf("%0*d", places+1, u)
+	return sign + s[:len(s)-places] + "." + s[len(s)-places:]
+}
+
+func unmarshalFixed(b []byte, places int) (int64, error) {
+	s := string(b)
+	if s == "null" {
+		return 0, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return 0, err
+		}
+	}
+	return parseFixed(s, places)
+}
+
+func scanFixed(src interface{}, places int) (int64, error) {
+	switch v := src.(type) {
+	case []byte:
+		return parseFixed(string(v), places)
+	case string:
+		return parseFixed(v, places)
+	case int64:
+		return parseFixed(fmt.Sprint(v), places)
+	case float64:
+		return parseFixed(fmt.Sprint(v), places)
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("decimal: unsupported type %T", src)
+}
+
+// roundRat округляет до целого, половину — от нуля.
+func roundRat(r *big.Rat) int64 {
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if new(big.Int).Mul(m.Abs(m), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// ExchangeRate — курс валюты к базовой; таблицу ведёт администратор.
+type ExchangeRate struct {
+	ID        int       `json:"id" db:"id"`
+	Currency  Currency  `json:"currency" db:"currency"`
+	Rate      Rate      `json:"rate" db:"rate"`
+	OwnerID   int       `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (e ExchangeRate) GetNameTable() string {
+	return "exchange_rates"
+}
+func (e ExchangeRate) GetNameColumns() string {
+	return "currency, rate"
+}
+func (e ExchangeRate) GetPlaceholder() string {
+	return "$1, $2"
+}
+func (e ExchangeRate) GetValues() []interface{} {
+	return []interface{}{
+		e.Currency, e.Rate,
+	}
+}
+func (e ExchangeRate) GetUpdatedAt() time.Time {
+	return e.UpdatedAt
+}
+func (e ExchangeRate) Validate() error {
+	if e.Currency == "" {
+		return fmt.Errorf("currency is required")
+	}
+	if err := e.Currency.Validate(); err != nil {
+		return err
+	}
+	if e.Currency.String() == BaseCurrency {
+		return fmt.Errorf("%s is the base currency, its rate is always 1", BaseCurrency)
+	}
+	if e.Rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	return nil
+}
+func (e ExchangeRate) GetFilters() map[string]Filter {
+	// фильтра по валюте нет: ?currency= занят пересчётом (см. Currencies)
+	return map[string]Filter{}
+}
+func (e ExchangeRate) GetSortColumns() []string {
+	return []string{"id", "currency", "updated_at"}
+}
+
+// Converter пересчитывает суммы в валюту To по курсам на момент запроса.
+type Converter struct {
+	To    Currency
+	rates map[Currency]Rate
+}
+
+// Convert — сумма в валюте To; false, если курса исходной валюты нет.
+func (c *Converter) Convert(a Amount, from Currency) (Amount, bool) {
+	return c.convertBetween(a, from, c.To)
+}
+
+// convertBetween пересчитывает сумму между любыми валютами, курсы которых загружены.
+func (c *Converter) convertBetween(a Amount, from, to Currency) (Amount, bool) {
+	rf, ok := c.rates[Currency(from.String())]
+	if !ok {
+		return 0, false
+	}
+	rt, ok := c.rates[Currency(to.String())]
+	if !ok {
+		return 0, false
+	}
+	return a.Convert(rf, rt), true
+}
+
+// Converted — денежные поля записи в запрошенной валюте; ключи — имена полей в JSON.
+// Отдаётся рядом с исходными значениями, только если передан ?currency=.
+type Converted struct {
+	Currency Currency
+	Values   map[string]Amount
+}
+
+func (c Converted) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{"currency": c.Currency}
+	for k, v := range c.Values {
+		out[k] = v
+	}
+	return json.Marshal(out)
+}
+
+// converter — записи с денежными полями, которые умеют показать их в другой валюте.
+type converter[T any] interface {
+	withConverted(c *Converter) T
+}
+
+// convertAll заполняет Converted у записей, если в контексте есть Converter.
+func convertAll[T any](ctx context.Context, items []T) {
+	c, ok := ctx.Value(converterKey).(*Converter)
+	if !ok {
+		return
+	}
+	for i, item := range items {
+		if cv, ok := interface{}(item).(converter[T]); ok {
+			items[i] = cv.withConverted(c)
+		}
+	}
+}
+
+// convertOne — convertAll для одной записи.
+func convertOne[T any](ctx context.Context, item T) T {
+	items := []T{item}
+	convertAll(ctx, items)
+	return items[0]
+}
+
+// convertFields собирает Converted из пар «имя поля — сумма» в валюте from.
+func convertFields(c *Converter, from Currency, fields map[string]Amount) *Converted {
+	out := &Converted{Currency: c.To, Values: map[string]Amount{}}
+	for name, a := range fields {
+		if v, ok := c.Convert(a, from); ok {
+			out.Values[name] = v
+		}
+	}
+	return out
+}
+
+// Currencies — middleware для ?currency=: загружает курсы и кладёт Converter в контекст.
+// Неизвестная валюта — 400. Фильтры и сортировка по сумме при ?currency= сравнивают
+// суммы, пересчитанные в эту валюту (см. ListQuery.Currency).
+func Currencies(rates Repository[ExchangeRate]) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			to := Currency(strings.ToUpper(r.URL.Query().Get("currency")))
+			if to == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			c, err := loadConverter(r.Context(), rates, to)
+			if errors.Is(err, errUnknownCurrency) {
+				http.Error(w, "Unknown currency: "+to.String(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				writeRepoError(w, "Currencies", err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withConverter(r.Context(), c)))
+		})
+	}
+}
+
+var errUnknownCurrency = errors.New("unknown currency")
+
+// loadConverter загружает текущие курсы для пересчёта в to; errUnknownCurrency — курса to нет.
+func loadConverter(ctx context.Context, rates Repository[ExchangeRate], to Currency) (*Converter, error) {
+	c := &Converter{To: to, rates: map[Currency]Rate{BaseCurrency: baseRate}}
+	err := rates.Each(ctx, &ListQuery{}, func(e ExchangeRate) error {
+		c.rates[e.Currency] = e.Rate
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.rates[Currency(to.String())]; !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownCurrency, to)
+	}
+	return c, nil
+}
+
+// withConverter кладёт Converter в контекст: его читают convertAll и выборки в памяти.
+func withConverter(ctx context.Context, c *Converter) context.Context {
+	return context.WithValue(ctx, converterKey, c)
+}
@@ -0,0 +1,90 @@
+package estate
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"42", 4200, false},
+		{"42.5", 4250, false},
+		{"-0.01", -1, false},
+		{" 1e3 ", 100000, false},
+		{"0.005", 0, true},
+		{"abc", 0, true},
+		{"", 0, true},
+		{"1e30", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAmount(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{"1", baseRate, false},
+		{"1.1", 110000000, false},
+		{"0.00000001", 1, false},
+		{"0.000000001", 0, true},
+		{"x", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`100`, "100.00"},
+		{`"99.9"`, "99.90"},
+		{`-0.5`, "-0.50"},
+		{`null`, "0.00"},
+	}
+	for _, tt := range tests {
+		var a Amount
+		if err := a.UnmarshalJSON([]byte(tt.in)); err != nil {
+			t.Errorf("UnmarshalJSON(%s): %v", tt.in, err)
+			continue
+		}
+		if a.String() != tt.want {
+			t.Errorf("UnmarshalJSON(%s) = %s, want %s", tt.in, a, tt.want)
+		}
+	}
+}
+
+func TestConverter(t *testing.T) {
+	c := &Converter{To: "EUR", rates: map[Currency]Rate{"USD": baseRate, "EUR": 110000000}}
+	tests := []struct {
+		amount Amount
+		from   Currency
+		want   Amount
+		ok     bool
+	}{
+		{11000, "USD", 10000, true},
+		{10000, "EUR", 10000, true},
+		{1, "USD", 1, true},
+		{10000, "TJS", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := c.Convert(tt.amount, tt.from)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Convert(%s %s) = %s, %v; want %s, %v", tt.amount, tt.from, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/store"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Статусы предложения. Ответа ждёт только pending; встречное предложение
+// закрывает исходное статусом countered. Принятое переводит объект в under_contract,
+// а при cancel_contract закрывается статусом withdrawn.
+const (
+	OfferPending   = "pending"
+	OfferAccepted  = "accepted"
+	OfferRejected  = "rejected"
+	OfferCountered = "countered"
+	OfferWithdrawn = "withdrawn"
+	OfferExpired   = "expired"
+)
+
+// Стороны переговоров: кто сделал предложение.
+const (
+	OfferByBuyer  = "buyer"
+	OfferBySeller = "seller"
+)
+
+// Offer — ценовое предложение по объекту. Переписка — все предложения
+// с одними property_id и buyer_id; owner_id — агент (владелец) объекта.
+type Offer struct {
+	ID         int        `json:"id" db:"id"`
+	PropertyID int        `json:"property_id" db:"property_id"`
+	BuyerID    int        `json:"buyer_id" db:"buyer_id"`
+	ParentID   *int       `json:"parent_id" db:"parent_id"`
+	Amount     Amount     `json:"amount" db:"amount"`
+	Currency   Currency   `json:"currency" db:"currency"`
+	Message    string     `json:"message" db:"message"`
+	MadeBy     string     `json:"made_by" db:"made_by"`
+	Status     string     `json:"status" db:"status"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	OwnerID    int        `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"-" db:"updated_at"`
+	Converted  *Converted `json:"converted,omitempty" db:"-"`
+}
+
+func (o Offer) GetNameTable() string {
+	return "offers"
+}
+func (o Offer) GetNameColumns() string {
+	return "property_id, buyer_id, parent_id, amount, currency, message, made_by, expires_at"
+}
+func (o Offer) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6, $7, $8"
+}
+func (o Offer) GetValues() []interface{} {
+	return []interface{}{
+		o.PropertyID, o.BuyerID, o.ParentID, o.Amount, o.Currency, o.Message, o.MadeBy, o.ExpiresAt,
+	}
+}
+func (o Offer) GetUpdatedAt() time.Time {
+	return o.UpdatedAt
+}
+
+// Validate проверяет то, что задаёт автор предложения; property_id для встречного
+// предложения берётся из исходного.
+func (o Offer) Validate() error {
+	if o.Amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if err := o.Currency.Validate(); err != nil {
+		return err
+	}
+	if !o.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+func (o Offer) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"property_id": {Column: "property_id", Op: "=", Kind: KindInt},
+		"buyer_id":    {Column: "buyer_id", Op: "=", Kind: KindInt},
+		"status":      {Column: "status", Op: "=", Kind: KindString},
+		"made_by":     {Column: "made_by", Op: "=", Kind: KindString},
+		"owner_id":    {Column: "owner_id", Op: "=", Kind: KindInt},
+		"amount_min":  {Column: "amount", Op: ">=", Kind: KindDecimal},
+		"amount_max":  {Column: "amount", Op: "<=", Kind: KindDecimal},
+	}
+}
+func (o Offer) GetSortColumns() []string {
+	return []string{"id", "amount", "expires_at", "created_at"}
+}
+func (o Offer) withConverted(c *Converter) Offer {
+	o.Converted = convertFields(c, o.Currency, map[string]Amount{"amount": o.Amount})
+	return o
+}
+func (o Offer) withDefaults() Offer {
+	if o.Status == "" {
+		o.Status = OfferPending
+	}
+	return o
+}
+
+// isParty — участник переписки: покупатель, агент объекта или админ.
+func (o Offer) isParty(userID, role int) bool {
+	return userID == o.BuyerID || userID == o.OwnerID || role == store.RoleAdmin
+}
+
+// canRespond — отвечает сторона, которая предложение не делала; за продавца может и админ.
+func (o Offer) canRespond(userID, role int) bool {
+	if o.MadeBy == OfferByBuyer {
+		return userID == o.OwnerID || role == store.RoleAdmin
+	}
+	return userID == o.BuyerID
+}
+
+// AcceptedOffer — ответ на принятие: предложение и заготовка продажи по согласованной цене.
+type AcceptedOffer struct {
+	Offer Offer `json:"offer"`
+	Sale  Sale  `json:"sale"`
+}
+
+func (o Offer) saleDraft() Sale {
+	return Sale{PropertyID: o.PropertyID, BuyerID: o.BuyerID, FinalPrice: o.Amount, Currency: o.Currency}
+}
+
+// OfferService ведёт переговоры о цене между покупателем и агентом объекта.
+type OfferService struct {
+	tx         Transactor
+	lifecycle  *Lifecycle
+	properties Repository[Property]
+	offers     Repository[Offer]
+	sales      *SaleService
+}
+
+func NewOfferService(repos *Repositories) *OfferService {
+	return &OfferService{
+		tx:         repos.Tx,
+		lifecycle:  NewLifecycle(repos),
+		properties: repos.Properties,
+		offers:     repos.Offers,
+		sales:      NewSaleService(repos),
+	}
+}
+
+// onSale — объект ещё продаётся: по нему можно предлагать, отвечать и соглашаться.
+// После принятого предложения объект уже under_contract.
+func onSale(p Property) error {
+	if p.Status != StatusAvailable && p.Status != StatusReserved {
+		return fmt.Errorf("%w: property %d is %s", ErrConflict, p.ID, p.Status)
+	}
+	return nil
+}
+
+// lockListing блокирует объект предложения до конца транзакции: предложения,
+// ответы и принятие по одному объекту выполняются по очереди.
+func (s *OfferService) lockListing(ctx context.Context, propertyID int) (Property, error) {
+	p, err := s.properties.Lock(ctx, propertyID)
+	if errors.Is(err, ErrNotFound) {
+		return p, fmt.Errorf("%w: property %d is no longer listed", ErrConflict, propertyID)
+	}
+	return p, err
+}
+
+// Submit — первое (или новое после закрытой переписки) предложение покупателя.
+// ErrConflict — объект не продаётся или предложение покупателя уже ждёт ответа.
+func (s *OfferService) Submit(ctx context.Context, o Offer, buyerID int) (Offer, error) {
+	var saved Offer
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		p, err := s.properties.Lock(ctx, o.PropertyID)
+		if errors.Is(err, ErrNotFound) {
+			return newStatusError(http.StatusUnprocessableEntity, "property %d not found", o.PropertyID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := onSale(p); err != nil {
+			return err
+		}
+		if p.OwnerID == buyerID {
+			return fmt.Errorf("%w: owner cannot make an offer on own property", ErrConflict)
+		}
+		q := &ListQuery{
+			Conditions: []Condition{
+				{Column: "property_id", Op: "=", Value: p.ID},
+				{Column: "buyer_id", Op: "=", Value: buyerID},
+				{Column: "status", Op: "=", Value: OfferPending},
+			},
+			Limit:   1,
+			NoTotal: true,
+		}
+		pending, _, err := s.offers.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%w: offer %d is still pending", ErrConflict, pending[0].ID)
+		}
+		o.BuyerID, o.ParentID, o.MadeBy = buyerID, nil, OfferByBuyer
+		if o.Currency == "" {
+			o.Currency = p.Currency
+		}
+		rows, err := s.offers.Insert(ctx, p.OwnerID, o)
+		if err != nil {
+			return err
+		}
+		saved = rows[0]
+		return nil
+	})
+	return saved, err
+}
+
+// respond закрывает ожидающее предложение статусом status от имени ответной стороны.
+func (s *OfferService) respond(ctx context.Context, id, userID, role int, status string) (Offer, error) {
+	now := time.Now()
+	return s.offers.SetColumn(ctx, id, "status", status, func(current Offer) error {
+		if !current.isParty(userID, role) {
+			return ErrNotFound
+		}
+		if !current.canRespond(userID, role) {
+			return newStatusError(http.StatusForbidden, "Недостаточно прав доступа")
+		}
+		if current.Status != OfferPending {
+			return fmt.Errorf("%w: offer %d is %s", ErrConflict, id, current.Status)
+		}
+		if !current.ExpiresAt.After(now) {
+			return fmt.Errorf("%w: offer %d has expired", ErrConflict, id)
+		}
+		return nil
+	})
+}
+
+// Accept принимает предложение, если объект ещё продаётся: объект переходит в
+// under_contract, остальные ожидающие предложения по нему отклоняются. После этого
+// новые предложения, встречные и принятия по объекту получают ErrConflict.
+func (s *OfferService) Accept(ctx context.Context, id, userID, role int) (Offer, error) {
+	var accepted Offer
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		o, err := s.offers.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		p, err := s.lockListing(ctx, o.PropertyID)
+		if err != nil {
+			return err
+		}
+		if accepted, err = s.respond(ctx, id, userID, role, OfferAccepted); err != nil {
+			return err
+		}
+		if err := onSale(p); err != nil {
+			return err
+		}
+		if _, err := s.lifecycle.fire(ctx, p.ID, EventContract, nil); err != nil {
+			return err
+		}
+		return s.rejectPending(ctx, p.ID, id)
+	})
+	return accepted, err
+}
+
+// rejectPending отклоняет ожидающие предложения по объекту, кроме принятого.
+func (s *OfferService) rejectPending(ctx context.Context, propertyID, acceptedID int) error {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "property_id", Op: "=", Value: propertyID},
+			{Column: "status", Op: "=", Value: OfferPending},
+			{Column: "id", Op: "!=", Value: acceptedID},
+		},
+		NoTotal: true,
+	}
+	pending, _, err := s.offers.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, o := range pending {
+		_, err := s.offers.SetColumn(ctx, o.ID, "status", OfferRejected, func(current Offer) error {
+			if current.Status != OfferPending {
+				return fmt.Errorf("%w: offer %d is %s", ErrConflict, current.ID, current.Status)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *OfferService) Reject(ctx context.Context, id, userID, role int) (Offer, error) {
+	return s.respond(ctx, id, userID, role, OfferRejected)
+}
+
+// Counter закрывает предложение как countered и создаёт встречное от другой стороны.
+func (s *OfferService) Counter(ctx context.Context, id, userID, role int, counter Offer) (Offer, error) {
+	var saved Offer
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		o, err := s.offers.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		p, err := s.lockListing(ctx, o.PropertyID)
+		if err != nil {
+			return err
+		}
+		parent, err := s.respond(ctx, id, userID, role, OfferCountered)
+		if err != nil {
+			return err
+		}
+		if err := onSale(p); err != nil {
+			return err
+		}
+		madeBy := OfferBySeller
+		if parent.MadeBy == OfferBySeller {
+			madeBy = OfferByBuyer
+		}
+		if counter.Currency == "" {
+			counter.Currency = parent.Currency
+		}
+		next := Offer{
+			PropertyID: parent.PropertyID,
+			BuyerID:    parent.BuyerID,
+			ParentID:   &parent.ID,
+			Amount:     counter.Amount,
+			Currency:   counter.Currency,
+			Message:    counter.Message,
+			MadeBy:     madeBy,
+			ExpiresAt:  counter.ExpiresAt,
+		}
+		rows, err := s.offers.Insert(ctx, parent.OwnerID, next)
+		if err != nil {
+			return err
+		}
+		saved = rows[0]
+		return nil
+	})
+	return saved, err
+}
+
+// Withdraw — автор отзывает своё ожидающее предложение.
+func (s *OfferService) Withdraw(ctx context.Context, id, userID, role int) (Offer, error) {
+	return s.offers.SetColumn(ctx, id, "status", OfferWithdrawn, func(current Offer) error {
+		if !current.isParty(userID, role) {
+			return ErrNotFound
+		}
+		if current.canRespond(userID, role) {
+			return newStatusError(http.StatusForbidden, "only the author can withdraw an offer")
+		}
+		if current.Status != OfferPending {
+			return fmt.Errorf("%w: offer %d is %s", ErrConflict, id, current.Status)
+		}
+		return nil
+	})
+}
+
+// Thread — вся переписка, в которую входит предложение id, от первого к последнему.
+func (s *OfferService) Thread(ctx context.Context, id, userID, role int) ([]Offer, error) {
+	o, err := s.offers.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !o.isParty(userID, role) {
+		return nil, ErrNotFound
+	}
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "property_id", Op: "=", Value: o.PropertyID},
+			{Column: "buyer_id", Op: "=", Value: o.BuyerID},
+		},
+		SortColumn: "id",
+		NoTotal:    true,
+	}
+	thread, _, err := s.offers.List(ctx, q)
+	return thread, err
+}
+
+// StartSale оформляет продажу по принятому предложению с согласованной ценой.
+func (s *OfferService) StartSale(ctx context.Context, id, userID, role int) (Sale, error) {
+	o, err := s.offers.Get(ctx, id)
+	if err != nil {
+		return Sale{}, err
+	}
+	if !o.isParty(userID, role) {
+		return Sale{}, ErrNotFound
+	}
+	if userID != o.OwnerID && role != store.RoleAdmin {
+		return Sale{}, newStatusError(http.StatusForbidden, "Недостаточно прав доступа")
+	}
+	if o.Status != OfferAccepted {
+		return Sale{}, fmt.Errorf("%w: offer %d is %s", ErrConflict, id, o.Status)
+	}
+	return s.sales.Register(ctx, o.saleDraft(), userID)
+}
+
+// Expire закрывает ожидающие предложения, срок которых истёк к now.
+func (s *OfferService) Expire(ctx context.Context, now time.Time) (int, error) {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "status", Op: "=", Value: OfferPending},
+			{Column: "expires_at", Op: "<=", Value: now},
+		},
+		NoTotal: true,
+	}
+	due, _, err := s.offers.List(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, o := range due {
+		_, err := s.offers.SetColumn(ctx, o.ID, "status", OfferExpired, func(current Offer) error {
+			if current.Status != OfferPending {
+				return fmt.Errorf("%w: offer %d is %s", ErrConflict, current.ID, current.Status)
+			}
+			return nil
+		})
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Create — POST /offers: предложение покупателя из токена.
+func (s *OfferService) Create(w http.ResponseWriter, r *http.Request) {
+	var o Offer
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := o.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if o.PropertyID <= 0 {
+		http.Error(w, "property_id is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	saved, err := s.Submit(requestContext(r), o, userID)
+	if err != nil {
+		writeRepoError(w, "SubmitOffer", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// offerParty — id и роль пользователя для маршрутов /offers/{id}/...; роль кладёт Access.LoadRole.
+func offerParty(w http.ResponseWriter, r *http.Request) (id, userID, role int, ok bool) {
+	if id, ok = urlID(w, r); !ok {
+		return
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return id, 0, 0, false
+	}
+	role, _ = roleFrom(r.Context())
+	return id, userID, role, true
+}
+
+// AcceptHandler — POST /offers/{id}/accept; в ответе заготовка продажи для POST /sales.
+func (s *OfferService) AcceptHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, role, ok := offerParty(w, r)
+	if !ok {
+		return
+	}
+	o, err := s.Accept(requestContext(r), id, userID, role)
+	if err != nil {
+		writeRepoError(w, "AcceptOffer", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, AcceptedOffer{Offer: o, Sale: o.saleDraft()})
+}
+
+func (s *OfferService) RejectHandler(w http.ResponseWriter, r *http.Request) {
+	s.offerAction(w, r, "RejectOffer", s.Reject)
+}
+
+func (s *OfferService) WithdrawHandler(w http.ResponseWriter, r *http.Request) {
+	s.offerAction(w, r, "WithdrawOffer", s.Withdraw)
+}
+
+func (s *OfferService) offerAction(w http.ResponseWriter, r *http.Request, op string, fn func(ctx context.Context, id, userID, role int) (Offer, error)) {
+	id, userID, role, ok := offerParty(w, r)
+	if !ok {
+		return
+	}
+	o, err := fn(requestContext(r), id, userID, role)
+	if err != nil {
+		writeRepoError(w, op, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// CounterHandler — POST /offers/{id}/counter с телом {"amount", "expires_at", "message"}.
+func (s *OfferService) CounterHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, role, ok := offerParty(w, r)
+	if !ok {
+		return
+	}
+	var counter Offer
+	if err := json.NewDecoder(r.Body).Decode(&counter); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := counter.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	o, err := s.Counter(requestContext(r), id, userID, role, counter)
+	if err != nil {
+		writeRepoError(w, "CounterOffer", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// ThreadHandler — GET /offers/{id}/thread: переписка видна обеим сторонам.
+func (s *OfferService) ThreadHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, role, ok := offerParty(w, r)
+	if !ok {
+		return
+	}
+	thread, err := s.Thread(r.Context(), id, userID, role)
+	if err != nil {
+		writeRepoError(w, "OfferThread", err)
+		return
+	}
+	convertAll(r.Context(), thread)
+	writeJSON(w, http.StatusOK, thread)
+}
+
+// SaleHandler — POST /offers/{id}/sale: продажа по принятому предложению.
+func (s *OfferService) SaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, userID, role, ok := offerParty(w, r)
+	if !ok {
+		return
+	}
+	sale, err := s.StartSale(requestContext(r), id, userID, role)
+	if err != nil {
+		writeRepoError(w, "OfferSale", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sale)
+}
+
+// Mine — GET /offers/my: предложения, где пользователь из токена — покупатель.
+func (s *OfferService) Mine(w http.ResponseWriter, r *http.Request) {
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	lq, err := parseListQuery(Offer{}, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lq.Conditions = append(lq.Conditions, Condition{Column: "buyer_id", Op: "=", Value: userID})
+	writeList(w, r, lq, s.offers.List)
+}
@@ -0,0 +1,406 @@
+package estate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// Validator — необязательная проверка записи перед сохранением.
+type Validator interface {
+	Validate() error
+}
+
+// jsonPatchOp — одна операция RFC 6902.
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// errUnchanged — патч ничего не меняет; запись не сохраняется.
+var errUnchanged = errors.New("unchanged")
+
+// Patch частично обновляет запись: RFC 7396 (merge patch, по умолчанию)
+// или RFC 6902 (JSON Patch) в зависимости от Content-Type.
+// Меняются только переданные поля; в ответе — обновлённая запись.
+func (h *Handler[T]) Patch(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var unchanged T
+	result, err := h.repo.Update(requestContext(r), id, func(current T) (T, error) {
+		if err := checkIfMatch(r, current); err != nil {
+			return current, err
+		}
+		patched, touched, err := applyPatch(current, r.Header.Get("Content-Type"), body)
+		if err != nil {
+			return current, newStatusError(http.StatusBadRequest, "%v", err)
+		}
+		cols, err := patchColumns(patched, touched)
+		if err != nil {
+			return current, newStatusError(http.StatusUnprocessableEntity, "%v", err)
+		}
+		if v, ok := interface{}(patched).(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return current, newStatusError(http.StatusUnprocessableEntity, "%v", err)
+			}
+		}
+		if len(cols) == 0 {
+			unchanged = current
+			return current, errUnchanged
+		}
+		return patched, nil
+	})
+	if errors.Is(err, errUnchanged) {
+		w.Header().Set("ETag", computeETag(unchanged))
+		writeJSON(w, http.StatusOK, unchanged)
+		return
+	}
+	if err != nil {
+		writeRepoError(w, "Patch", err)
+		return
+	}
+	w.Header().Set("ETag", computeETag(result))
+	writeJSON(w, http.StatusOK, result)
+}
+
+// applyPatch применяет патч к JSON-представлению записи и возвращает
+// новую запись и список затронутых полей верхнего уровня.
+func applyPatch[T Helper](current T, contentType string, body []byte) (T, map[string]bool, error) {
+	var patched T
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return patched, nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return patched, nil, err
+	}
+
+	touched := map[string]bool{}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case jsonPatchType:
+		var ops []jsonPatchOp
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return patched, nil, fmt.Errorf("invalid JSON Patch document")
+		}
+		for _, op := range ops {
+			if op.Op == "test" {
+				continue
+			}
+			touched[topLevelKey(op.Path)] = true
+			if op.Op == "move" {
+				touched[topLevelKey(op.From)] = true
+			}
+		}
+		if doc, err = applyJSONPatch(doc, ops); err != nil {
+			return patched, nil, err
+		}
+	case mergePatchType, "application/json", "":
+		var p interface{}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return patched, nil, fmt.Errorf("invalid merge patch document")
+		}
+		obj, ok := p.(map[string]interface{})
+		if !ok {
+			return patched, nil, fmt.Errorf("merge patch must be a JSON object")
+		}
+		for k := range obj {
+			touched[k] = true
+		}
+		doc = mergePatch(doc, p)
+	default:
+		return patched, nil, fmt.Errorf("unsupported patch type: %s", mediaType)
+	}
+
+	raw, err = json.Marshal(doc)
+	if err != nil {
+		return patched, nil, err
+	}
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return patched, nil, fmt.Errorf("invalid field value: %v", err)
+	}
+	return patched, touched, nil
+}
+
+// patchColumns выбирает из обновляемых колонок Helper только затронутые патчем.
+// Поле, которое нельзя обновлять (id, owner_id и т.п.), — ошибка.
+func patchColumns(item Helper, touched map[string]bool) ([]string, error) {
+	jsonNames := jsonNamesByColumn(item)
+
+	var cols []string
+	known := map[string]bool{}
+	for _, c := range strings.Split(item.GetNameColumns(), ", ") {
+		name := jsonNames[c]
+		known[name] = true
+		if touched[name] {
+			cols = append(cols, c)
+		}
+	}
+	for k := range touched {
+		if !known[k] {
+			return nil, fmt.Errorf("field is not patchable: %s", k)
+		}
+	}
+	return cols, nil
+}
+
+// jsonNamesByColumn сопоставляет колонку (тег db) с именем поля в JSON.
+func jsonNamesByColumn(item interface{}) map[string]string {
+	names := map[string]string{}
+	tm := columnMapper.TypeMap(reflect.TypeOf(item))
+	for col, fi := range tm.Names {
+		name := strings.Split(fi.Field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = fi.Field.Name
+		}
+		names[col] = name
+	}
+	return names
+}
+
+func topLevelKey(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	return unescapePointer(parts[0])
+}
+
+// mergePatch — RFC 7396: null удаляет поле, объекты сливаются рекурсивно, остальное заменяется.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+func applyJSONPatch(doc interface{}, ops []jsonPatchOp) (interface{}, error) {
+	var err error
+	for _, op := range ops {
+		var value interface{}
+		if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+			if len(op.Value) == 0 {
+				return nil, fmt.Errorf("%s operation requires value", op.Op)
+			}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, fmt.Errorf("invalid value for %s", op.Path)
+			}
+		}
+		switch op.Op {
+		case "add":
+			doc, err = pointerSet(doc, op.Path, value, true)
+		case "replace":
+			if _, err = pointerGet(doc, op.Path); err == nil {
+				doc, err = pointerSet(doc, op.Path, value, false)
+			}
+		case "remove":
+			doc, err = pointerRemove(doc, op.Path)
+		case "move":
+			if value, err = pointerGet(doc, op.From); err == nil {
+				if doc, err = pointerRemove(doc, op.From); err == nil {
+					doc, err = pointerSet(doc, op.Path, value, true)
+				}
+			}
+		case "copy":
+			if value, err = pointerGet(doc, op.From); err == nil {
+				doc, err = pointerSet(doc, op.Path, value, true)
+			}
+		case "test":
+			var actual interface{}
+			if actual, err = pointerGet(doc, op.Path); err == nil && !reflect.DeepEqual(actual, value) {
+				err = fmt.Errorf("test failed for %s", op.Path)
+			}
+		default:
+			err = fmt.Errorf("unknown operation: %s", op.Op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func unescapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
+
+func splitPointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	parts := strings.Split(path[1:], "/")
+	for i := range parts {
+		parts[i] = unescapePointer(parts[i])
+	}
+	return parts, nil
+}
+
+func arrayIndex(s string, n int, allowEnd bool) (int, error) {
+	if allowEnd && s == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(s)
+	max := n - 1
+	if allowEnd {
+		max = n
+	}
+	if err != nil || i < 0 || i > max {
+		return 0, fmt.Errorf("invalid array index: %s", s)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, path string) (interface{}, error) {
+	parts, err := splitPointer(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := doc
+	for _, p := range parts {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			v, ok := node[p]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %s", path)
+			}
+			cur = v
+		case []interface{}:
+			i, err := arrayIndex(p, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = node[i]
+		default:
+			return nil, fmt.Errorf("path not found: %s", path)
+		}
+	}
+	return cur, nil
+}
+
+// pointerSet записывает значение по указателю; insert — семантика add (вставка в массив).
+func pointerSet(doc interface{}, path string, value interface{}, insert bool) (interface{}, error) {
+	parts, err := splitPointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return value, nil
+	}
+	parentParts := parts[:len(parts)-1]
+	parentPath := "/" + strings.Join(escapeAll(parentParts), "/")
+	parent := doc
+	if len(parentParts) > 0 {
+		if parent, err = pointerGet(doc, parentPath); err != nil {
+			return nil, err
+		}
+	}
+	last := parts[len(parts)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+	case []interface{}:
+		i, err := arrayIndex(last, len(node), insert)
+		if err != nil {
+			return nil, err
+		}
+		if !insert {
+			node[i] = value
+			return doc, nil
+		}
+		node = append(node[:i], append([]interface{}{value}, node[i:]...)...)
+		if len(parentParts) == 0 {
+			return node, nil
+		}
+		return pointerSet(doc, parentPath, node, false)
+	default:
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+	return doc, nil
+}
+
+func pointerRemove(doc interface{}, path string) (interface{}, error) {
+	parts, err := splitPointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("cannot remove document root")
+	}
+	parentPath := "/" + strings.Join(escapeAll(parts[:len(parts)-1]), "/")
+	parent := doc
+	if len(parts) > 1 {
+		if parent, err = pointerGet(doc, parentPath); err != nil {
+			return nil, err
+		}
+	}
+	last := parts[len(parts)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := node[last]; !ok {
+			return nil, fmt.Errorf("path not found: %s", path)
+		}
+		delete(node, last)
+	case []interface{}:
+		i, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node[:i], node[i+1:]...)
+		if len(parts) == 1 {
+			return node, nil
+		}
+		return pointerSet(doc, parentPath, node, false)
+	default:
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+	return doc, nil
+}
+
+func escapeAll(parts []string) []string {
+	out := make([]string, len(parts))
+	for i, p := range parts {
+		out[i] = strings.ReplaceAll(strings.ReplaceAll(p, "~", "~0"), "/", "~1")
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
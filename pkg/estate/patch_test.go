@@ -0,0 +1,83 @@
+package estate
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	lat := 38.56
+	current := Property{ID: 1, Address: "Rudaki 1", Type: "apartment", Price: 5000000, Currency: "USD", OwnerID: 2, Latitude: &lat}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        func(p Property) Property
+		wantCols    []string
+		wantErr     bool
+	}{
+		{
+			name: "merge patch", contentType: mergePatchType,
+			body:     `{"price":"45000","address":"Rudaki 2"}`,
+			want:     func(p Property) Property { p.Price, p.Address = 4500000, "Rudaki 2"; return p },
+			wantCols: []string{"address", "price"},
+		},
+		{
+			name: "plain json is a merge patch", contentType: "application/json; charset=utf-8",
+			body:     `{"type":"house"}`,
+			want:     func(p Property) Property { p.Type = "house"; return p },
+			wantCols: []string{"type"},
+		},
+		{
+			name: "merge patch null clears the field", contentType: mergePatchType,
+			body:     `{"latitude":null}`,
+			want:     func(p Property) Property { p.Latitude = nil; return p },
+			wantCols: []string{"latitude"},
+		},
+		{
+			name: "json patch", contentType: jsonPatchType,
+			body:     `[{"op":"test","path":"/type","value":"apartment"},{"op":"replace","path":"/price","value":"60000"}]`,
+			want:     func(p Property) Property { p.Price = 6000000; return p },
+			wantCols: []string{"price"},
+		},
+		{
+			name: "json patch copy", contentType: jsonPatchType,
+			body:     `[{"op":"copy","from":"/latitude","path":"/longitude"}]`,
+			want:     func(p Property) Property { p.Longitude = &lat; return p },
+			wantCols: []string{"longitude"},
+		},
+		{name: "failed test op", contentType: jsonPatchType, body: `[{"op":"test","path":"/type","value":"house"}]`, wantErr: true},
+		{name: "replace missing path", contentType: jsonPatchType, body: `[{"op":"replace","path":"/nope","value":1}]`, wantErr: true},
+		{name: "unknown op", contentType: jsonPatchType, body: `[{"op":"swap","path":"/type"}]`, wantErr: true},
+		{name: "merge patch not an object", contentType: mergePatchType, body: `[1]`, wantErr: true},
+		{name: "bad value", contentType: mergePatchType, body: `{"price":"abc"}`, wantErr: true},
+		{name: "unsupported type", contentType: "text/plain", body: `{}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, touched, err := applyPatch(current, tt.contentType, []byte(tt.body))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: applyPatch error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if want := tt.want(current); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: applyPatch = %+v, want %+v", tt.name, got, want)
+		}
+		cols, err := patchColumns(got, touched)
+		sort.Strings(cols)
+		if err != nil || !reflect.DeepEqual(cols, tt.wantCols) {
+			t.Errorf("%s: patchColumns = %v, %v; want %v", tt.name, cols, err, tt.wantCols)
+		}
+	}
+}
+
+func TestPatchColumnsRejectsReadOnlyFields(t *testing.T) {
+	for _, field := range []string{"id", "owner_id", "status", "unknown"} {
+		if _, err := patchColumns(Property{}, map[string]bool{field: true}); err == nil {
+			t.Errorf("patchColumns accepted %s", field)
+		}
+	}
+}
@@ -0,0 +1,630 @@
+package estate
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/storage"
+	"example-app/pkg/store"
+	"example-app/pkg/thumbnail"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Ограничения загрузки фотографий.
+const (
+	maxPhotoSize         = 10 << 20
+	maxPhotoPixels       = 40_000_000
+	maxPhotosPerProperty = 30
+	// maxPhotosPerUpload — сколько файлов можно передать одним запросом.
+	maxPhotosPerUpload = 10
+)
+
+// Размеры копий: длинная сторона в пикселях.
+const (
+	PhotoOriginal = "original"
+	PhotoMedium   = "medium"
+	PhotoThumb    = "thumb"
+
+	mediumSide = 800
+	thumbSide  = 200
+)
+
+// photoFormats — принимаемые типы (по содержимому, а не по заголовку клиента) и расширения.
+var photoFormats = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// PropertyPhoto — фотография объекта: оригинал и две уменьшенные копии в хранилище файлов.
+type PropertyPhoto struct {
+	ID          int       `json:"id" db:"id"`
+	PropertyID  int       `json:"property_id" db:"property_id"`
+	Position    int       `json:"position" db:"position"`
+	IsCover     bool      `json:"is_cover" db:"is_cover"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int       `json:"size" db:"size"`
+	Width       int       `json:"width" db:"width"`
+	Height      int       `json:"height" db:"height"`
+	OriginalKey string    `json:"-" db:"original_key"`
+	MediumKey   string    `json:"-" db:"medium_key"`
+	ThumbKey    string    `json:"-" db:"thumb_key"`
+	OwnerID     int       `json:"owner_id" db:"owner_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (p PropertyPhoto) GetNameTable() string {
+	return "property_photos"
+}
+func (p PropertyPhoto) GetNameColumns() string {
+	return "property_id, position, is_cover, content_type, size, width, height, original_key, medium_key, thumb_key"
+}
+func (p PropertyPhoto) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5, $6, $7, $8, $9, $10"
+}
+func (p PropertyPhoto) GetValues() []interface{} {
+	return []interface{}{
+		p.PropertyID, p.Position, p.IsCover, p.ContentType, p.Size, p.Width, p.Height,
+		p.OriginalKey, p.MediumKey, p.ThumbKey,
+	}
+}
+func (p PropertyPhoto) GetUpdatedAt() time.Time {
+	return p.UpdatedAt
+}
+func (p PropertyPhoto) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"property_id": {Column: "property_id", Op: "=", Kind: KindInt},
+	}
+}
+func (p PropertyPhoto) GetSortColumns() []string {
+	return []string{"id", "position", "created_at"}
+}
+
+// key — ключ копии size в хранилище.
+func (p PropertyPhoto) key(size string) (string, bool) {
+	switch size {
+	case PhotoOriginal:
+		return p.OriginalKey, true
+	case PhotoMedium:
+		return p.MediumKey, true
+	case PhotoThumb:
+		return p.ThumbKey, true
+	}
+	return "", false
+}
+
+func (p PropertyPhoto) keys() []string {
+	return []string{p.OriginalKey, p.MediumKey, p.ThumbKey}
+}
+
+// photoUpload — проверенный и уменьшенный файл, ещё не сохранённый.
+type photoUpload struct {
+	photo PropertyPhoto
+	ext   string
+	files map[string][]byte
+}
+
+// preparePhoto определяет тип по содержимому, проверяет размеры и готовит копии.
+// Уменьшенные копии — JPEG: прозрачность PNG и GIF заливается белым.
+func preparePhoto(data []byte) (photoUpload, error) {
+	if len(data) > maxPhotoSize {
+		return photoUpload{}, newStatusError(http.StatusRequestEntityTooLarge, "photo is larger than %d MB", maxPhotoSize>>20)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := photoFormats[contentType]
+	if !ok {
+		return photoUpload{}, newStatusError(http.StatusUnsupportedMediaType, "unsupported photo type %s: only JPEG, PNG and GIF are accepted", contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return photoUpload{}, newStatusError(http.StatusUnprocessableEntity, "invalid image: %v", err)
+	}
+	if cfg.Width*cfg.Height > maxPhotoPixels {
+		return photoUpload{}, newStatusError(http.StatusUnprocessableEntity, "photo is %dx%d, at most %d megapixels allowed", cfg.Width, cfg.Height, maxPhotoPixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return photoUpload{}, newStatusError(http.StatusUnprocessableEntity, "invalid image: %v", err)
+	}
+	medium := thumbnail.Fit(img, mediumSide)
+	mediumJPEG, err := thumbnail.JPEG(medium, 85)
+	if err != nil {
+		return photoUpload{}, err
+	}
+	thumbJPEG, err := thumbnail.JPEG(thumbnail.Fit(medium, thumbSide), 85)
+	if err != nil {
+		return photoUpload{}, err
+	}
+	return photoUpload{
+		photo: PropertyPhoto{
+			ContentType: contentType,
+			Size:        len(data),
+			Width:       cfg.Width,
+			Height:      cfg.Height,
+		},
+		ext:   ext,
+		files: map[string][]byte{PhotoOriginal: data, PhotoMedium: mediumJPEG, PhotoThumb: thumbJPEG},
+	}, nil
+}
+
+// PhotoService — фотографии объектов: загрузка, порядок, обложка и удаление.
+// Управлять фотографиями могут владелец объекта и администратор; смотреть — все.
+type PhotoService struct {
+	tx         Transactor
+	photos     Repository[PropertyPhoto]
+	properties Repository[Property]
+	files      storage.Storage
+}
+
+func NewPhotoService(repos *Repositories, files storage.Storage) *PhotoService {
+	return &PhotoService{
+		tx:         repos.Tx,
+		photos:     repos.Photos,
+		properties: repos.Properties,
+		files:      files,
+	}
+}
+
+// Photos — фотографии объекта по порядку показа.
+func (s *PhotoService) Photos(ctx context.Context, propertyID int) ([]PropertyPhoto, error) {
+	q := &ListQuery{
+		Conditions: []Condition{{Column: "property_id", Op: "=", Value: propertyID}},
+		SortColumn: "position",
+		NoTotal:    true,
+	}
+	photos, _, err := s.photos.List(ctx, q)
+	return photos, err
+}
+
+// Upload сохраняет файлы в хранилище и добавляет фотографии в конец списка объекта.
+// Первая фотография объекта становится обложкой. Если записи не сохранились,
+// файлы удаляются.
+func (s *PhotoService) Upload(ctx context.Context, propertyID, ownerID int, uploads []photoUpload) ([]PropertyPhoto, error) {
+	var stored []string
+	for i := range uploads {
+		id, err := randomKey()
+		if err != nil {
+			s.removeFiles(ctx, stored...)
+			return nil, err
+		}
+		prefix := fmt.Sprintf("properties/%d/photos/%s/", propertyID, id)
+		u := &uploads[i]
+		u.photo.PropertyID = propertyID
+		u.photo.OriginalKey = prefix + PhotoOriginal + "." + u.ext
+		u.photo.MediumKey = prefix + PhotoMedium + ".jpg"
+		u.photo.ThumbKey = prefix + PhotoThumb + ".jpg"
+		for _, size := range []string{PhotoOriginal, PhotoMedium, PhotoThumb} {
+			key, _ := u.photo.key(size)
+			contentType := "image/jpeg"
+			if size == PhotoOriginal {
+				contentType = u.photo.ContentType
+			}
+			if err := s.files.Put(ctx, key, u.files[size], contentType); err != nil {
+				s.removeFiles(ctx, stored...)
+				return nil, err
+			}
+			stored = append(stored, key)
+		}
+	}
+
+	var saved []PropertyPhoto
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.lockProperty(ctx, propertyID); err != nil {
+			return err
+		}
+		existing, err := s.Photos(ctx, propertyID)
+		if err != nil {
+			return err
+		}
+		if len(existing)+len(uploads) > maxPhotosPerProperty {
+			return newStatusError(http.StatusUnprocessableEntity, "a property can have at most %d photos", maxPhotosPerProperty)
+		}
+		photos := make([]PropertyPhoto, len(uploads))
+		for i, u := range uploads {
+			photos[i] = u.photo
+			photos[i].Position = len(existing) + i + 1
+		}
+		photos[0].IsCover = len(existing) == 0
+		saved, err = s.photos.Insert(ctx, ownerID, photos...)
+		return err
+	})
+	if err != nil {
+		s.removeFiles(ctx, stored...)
+		return nil, err
+	}
+	return saved, nil
+}
+
+// lockProperty блокирует объект до конца транзакции: позиции, обложку и лимит
+// фотографий одного объекта меняют по очереди.
+func (s *PhotoService) lockProperty(ctx context.Context, propertyID int) error {
+	_, err := s.properties.Lock(ctx, propertyID)
+	return err
+}
+
+// photo — фотография объекта propertyID; фотография другого объекта — ErrNotFound.
+func (s *PhotoService) photo(ctx context.Context, propertyID, photoID int) (PropertyPhoto, error) {
+	p, err := s.photos.Get(ctx, photoID)
+	if err != nil {
+		return p, err
+	}
+	if p.PropertyID != propertyID {
+		return PropertyPhoto{}, ErrNotFound
+	}
+	return p, nil
+}
+
+// Reorder задаёт порядок показа: ids — все фотографии объекта в новом порядке.
+func (s *PhotoService) Reorder(ctx context.Context, propertyID int, ids []int) ([]PropertyPhoto, error) {
+	var photos []PropertyPhoto
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.lockProperty(ctx, propertyID); err != nil {
+			return err
+		}
+		current, err := s.Photos(ctx, propertyID)
+		if err != nil {
+			return err
+		}
+		byID := make(map[int]PropertyPhoto, len(current))
+		for _, p := range current {
+			byID[p.ID] = p
+		}
+		if len(ids) != len(current) {
+			return newStatusError(http.StatusUnprocessableEntity, "ids must list all %d photos of the property", len(current))
+		}
+		photos = make([]PropertyPhoto, 0, len(ids))
+		for i, id := range ids {
+			p, ok := byID[id]
+			if !ok {
+				return newStatusError(http.StatusUnprocessableEntity, "photo %d is not a photo of the property or is listed twice", id)
+			}
+			delete(byID, id)
+			if p.Position != i+1 {
+				if p, err = s.setPosition(ctx, p.ID, i+1); err != nil {
+					return err
+				}
+			}
+			photos = append(photos, p)
+		}
+		return nil
+	})
+	return photos, err
+}
+
+func (s *PhotoService) setPosition(ctx context.Context, id, position int) (PropertyPhoto, error) {
+	return s.photos.Update(ctx, id, func(current PropertyPhoto) (PropertyPhoto, error) {
+		current.Position = position
+		return current, nil
+	})
+}
+
+func (s *PhotoService) setCover(ctx context.Context, id int, cover bool) (PropertyPhoto, error) {
+	return s.photos.Update(ctx, id, func(current PropertyPhoto) (PropertyPhoto, error) {
+		current.IsCover = cover
+		return current, nil
+	})
+}
+
+// SetCover делает фотографию обложкой объекта; прежняя обложка снимается.
+func (s *PhotoService) SetCover(ctx context.Context, propertyID, photoID int) (PropertyPhoto, error) {
+	var cover PropertyPhoto
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.lockProperty(ctx, propertyID); err != nil {
+			return err
+		}
+		p, err := s.photo(ctx, propertyID, photoID)
+		if err != nil {
+			return err
+		}
+		if p.IsCover {
+			cover = p
+			return nil
+		}
+		photos, err := s.Photos(ctx, propertyID)
+		if err != nil {
+			return err
+		}
+		// сначала снимаем старую обложку: обложка у объекта одна (уникальный индекс)
+		for _, other := range photos {
+			if other.IsCover {
+				if _, err := s.setCover(ctx, other.ID, false); err != nil {
+					return err
+				}
+			}
+		}
+		cover, err = s.setCover(ctx, p.ID, true)
+		return err
+	})
+	return cover, err
+}
+
+// Remove удаляет фотографию и сдвигает следующие за ней. Если удалена обложка,
+// обложкой становится первая из оставшихся. Файлы удаляются после фиксации.
+func (s *PhotoService) Remove(ctx context.Context, propertyID, photoID int) error {
+	var removed PropertyPhoto
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.lockProperty(ctx, propertyID); err != nil {
+			return err
+		}
+		p, err := s.photo(ctx, propertyID, photoID)
+		if err != nil {
+			return err
+		}
+		if err := s.photos.Delete(ctx, p.ID, nil); err != nil {
+			return err
+		}
+		removed = p
+		rest, err := s.Photos(ctx, propertyID)
+		if err != nil {
+			return err
+		}
+		for i, other := range rest {
+			if other.Position != i+1 {
+				if _, err := s.setPosition(ctx, other.ID, i+1); err != nil {
+					return err
+				}
+			}
+		}
+		if p.IsCover && len(rest) > 0 {
+			_, err = s.setCover(ctx, rest[0].ID, true)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.removeFiles(ctx, removed.keys()...)
+	return nil
+}
+
+// removeFiles удаляет файлы без отказа операции: осиротевший файл безопаснее
+// потерянной записи, ошибки только логируются.
+func (s *PhotoService) removeFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := s.files.Delete(ctx, key); err != nil {
+			log.Printf("photo storage: delete %s: %v", key, err)
+		}
+	}
+}
+
+func randomKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// canManage — фотографиями управляют владелец объекта и администратор.
+// Отвечает сам (404/403), если управлять нельзя.
+func (s *PhotoService) canManage(w http.ResponseWriter, r *http.Request, propertyID int) bool {
+	p, err := s.properties.Get(r.Context(), propertyID)
+	if err != nil {
+		writeRepoError(w, "PropertyPhotos", err)
+		return false
+	}
+	if role, _ := roleFrom(r.Context()); role == store.RoleAdmin {
+		return true
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if p.OwnerID != userID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// photoID разбирает {photoID} из пути; при ошибке сам отвечает 400.
+func photoID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "photoID"))
+	if err != nil {
+		http.Error(w, "Invalid photo ID format", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// UploadHandler — POST /properties/{id}/photos, multipart/form-data с одним
+// или несколькими файлами в поле "photo".
+func (s *PhotoService) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok || !s.canManage(w, r, id) {
+		return
+	}
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotosPerUpload*maxPhotoSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Expected multipart/form-data with a photo field", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	headers := r.MultipartForm.File["photo"]
+	if len(headers) == 0 {
+		http.Error(w, "photo file is required", http.StatusBadRequest)
+		return
+	}
+	if len(headers) > maxPhotosPerUpload {
+		http.Error(w, fmt.Sprintf("at most %d photos per request", maxPhotosPerUpload), http.StatusUnprocessableEntity)
+		return
+	}
+	uploads := make([]photoUpload, 0, len(headers))
+	for _, fh := range headers {
+		if fh.Size > maxPhotoSize {
+			http.Error(w, fmt.Sprintf("%s: photo is larger than %d MB", fh.Filename, maxPhotoSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			writeRepoError(w, "UploadPhoto", err)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxPhotoSize+1))
+		f.Close()
+		if err != nil {
+			writeRepoError(w, "UploadPhoto", err)
+			return
+		}
+		u, err := preparePhoto(data)
+		if err != nil {
+			var se *statusError
+			if errors.As(err, &se) {
+				http.Error(w, fh.Filename+": "+se.msg, se.status)
+				return
+			}
+			writeRepoError(w, "UploadPhoto", err)
+			return
+		}
+		uploads = append(uploads, u)
+	}
+	saved, err := s.Upload(requestContext(r), id, userID, uploads)
+	if err != nil {
+		writeRepoError(w, "UploadPhoto", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// List — GET /properties/{id}/photos: фотографии по порядку показа.
+func (s *PhotoService) List(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := s.properties.Get(r.Context(), id); err != nil {
+		writeRepoError(w, "PropertyPhotos", err)
+		return
+	}
+	photos, err := s.Photos(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, "PropertyPhotos", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, photos)
+}
+
+// File — GET /properties/{id}/photos/{photoID}/{size}, size: original, medium или thumb.
+// Ключи файлов не переиспользуются, поэтому ответ можно кэшировать навсегда.
+func (s *PhotoService) File(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	pid, ok := photoID(w, r)
+	if !ok {
+		return
+	}
+	p, err := s.photo(r.Context(), id, pid)
+	if err != nil {
+		writeRepoError(w, "PhotoFile", err)
+		return
+	}
+	size := chi.URLParam(r, "size")
+	key, ok := p.key(size)
+	if !ok {
+		http.Error(w, "size must be original, medium or thumb", http.StatusBadRequest)
+		return
+	}
+	f, err := s.files.Open(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		writeRepoError(w, "PhotoFile", err)
+		return
+	}
+	defer f.Close()
+	contentType := "image/jpeg"
+	if size == PhotoOriginal {
+		contentType = p.ContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+// OrderHandler — PUT /properties/{id}/photos/order с телом {"ids": [...]}.
+func (s *PhotoService) OrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok || !s.canManage(w, r, id) {
+		return
+	}
+	var body struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	photos, err := s.Reorder(requestContext(r), id, body.IDs)
+	if err != nil {
+		writeRepoError(w, "ReorderPhotos", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, photos)
+}
+
+// CoverHandler — POST /properties/{id}/photos/{photoID}/cover.
+func (s *PhotoService) CoverHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok || !s.canManage(w, r, id) {
+		return
+	}
+	pid, ok := photoID(w, r)
+	if !ok {
+		return
+	}
+	p, err := s.SetCover(requestContext(r), id, pid)
+	if err != nil {
+		writeRepoError(w, "PhotoCover", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// DeleteHandler — DELETE /properties/{id}/photos/{photoID}.
+func (s *PhotoService) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok || !s.canManage(w, r, id) {
+		return
+	}
+	pid, ok := photoID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.Remove(requestContext(r), id, pid); err != nil {
+		writeRepoError(w, "DeletePhoto", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Операция завершилась успешно!"})
+}
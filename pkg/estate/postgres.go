@@ -0,0 +1,449 @@
+package estate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Connect открывает пул соединений по CONNECT_SQL; его делят все хранилища и pkg/store.
+func Connect() (*sqlx.DB, error) {
+	db, err := sqlx.Connect("postgres", os.Getenv("CONNECT_SQL"))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+	return db, nil
+}
+
+// PostgresRepository — Repository поверх таблицы T.GetNameTable().
+type PostgresRepository[T Helper] struct {
+	db    *sqlx.DB
+	table string
+}
+
+// NewPostgresRepository паникует, если таблица T не входит в AllowedTables:
+// имя таблицы подставляется в SQL, и это ошибка сборки приложения, а не запроса.
+func NewPostgresRepository[T Helper](db *sqlx.DB) *PostgresRepository[T] {
+	var item T
+	table := item.GetNameTable()
+	if !isAllowedTable(table) {
+		panic("estate: table not allowed: " + table)
+	}
+	return &PostgresRepository[T]{db: db, table: table}
+}
+
+func (p *PostgresRepository[T]) List(ctx context.Context, q *ListQuery) ([]T, int, error) {
+	var item T
+	where, args := q.sqlWhere(item)
+	total := -1
+	if !q.NoTotal {
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", p.table, where)
+		if err := sqlx.GetContext(ctx, p.conn(ctx), &total, countQuery, args...); err != nil {
+			return nil, 0, err
+		}
+	}
+	result := []T{}
+	if err := sqlx.SelectContext(ctx, p.conn(ctx), &result, p.selectQuery(q, where), args...); err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func (p *PostgresRepository[T]) ListByOwner(ctx context.Context, ownerID int, q *ListQuery) ([]T, int, error) {
+	return p.List(ctx, q.withOwner(ownerID))
+}
+
+func (p *PostgresRepository[T]) Each(ctx context.Context, q *ListQuery, fn func(T) error) error {
+	var item T
+	where, args := q.sqlWhere(item)
+	rows, err := p.conn(ctx).QueryxContext(ctx, p.selectQuery(q, where), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row T
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (p *PostgresRepository[T]) selectQuery(q *ListQuery, where string) string {
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s", p.table, where, q.orderSQL(p.table))
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+	if q.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", q.Offset)
+	}
+	return query
+}
+
+func (p *PostgresRepository[T]) Get(ctx context.Context, id int) (T, error) {
+	var result T
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1%s", p.table, andNotDeleted(result))
+	err := sqlx.GetContext(ctx, p.conn(ctx), &result, query, id)
+	return result, notFound(err)
+}
+
+func (p *PostgresRepository[T]) Lock(ctx context.Context, id int) (T, error) {
+	tx, ok := ctx.Value(txKey).(*sqlx.Tx)
+	if !ok {
+		var zero T
+		return zero, errNoTx
+	}
+	return p.lock(ctx, tx, id)
+}
+
+func (p *PostgresRepository[T]) Insert(ctx context.Context, ownerID int, items ...T) ([]T, error) {
+	action := auditAction(ctx, AuditCreate)
+	saved := make([]T, 0, len(items))
+	err := inTx(ctx, p.db, func(tx *sqlx.Tx) error {
+		for i, it := range items {
+			var row T
+			if err := tx.GetContext(ctx, &row, buildInsert(it)+" RETURNING *", append(it.GetValues(), ownerID)...); err != nil {
+				if len(items) > 1 {
+					return fmt.Errorf("item %d: %w", i+1, constraint(err))
+				}
+				return constraint(err)
+			}
+			if err := p.audit(ctx, tx, action, recordID(row), nil, row); err != nil {
+				return err
+			}
+			saved = append(saved, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (p *PostgresRepository[T]) Update(ctx context.Context, id int, mutate func(current T) (T, error)) (T, error) {
+	var updated T
+	err := inTx(ctx, p.db, func(tx *sqlx.Tx) error {
+		current, err := p.lock(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		next, err := mutate(current)
+		if err != nil {
+			return err
+		}
+
+		cols := strings.Split(next.GetNameColumns(), ", ")
+		set := make([]string, len(cols))
+		for i, c := range cols {
+			set[i] = fmt.Sprintf("%s = $%d", c, i+1)
+		}
+		// id placeholder должен быть следующим по номеру
+		query := fmt.Sprintf(
+			"UPDATE %s SET %s WHERE id = $%d RETURNING *",
+			p.table,
+			versionedSet(next, strings.Join(set, ", ")),
+			len(cols)+1,
+		)
+		if err := tx.GetContext(ctx, &updated, query, append(next.GetValues(), id)...); err != nil {
+			return constraint(err)
+		}
+		return p.audit(ctx, tx, AuditUpdate, id, current, updated)
+	})
+	return updated, err
+}
+
+func (p *PostgresRepository[T]) SetColumn(ctx context.Context, id int, column string, value interface{}, check func(current T) error) (T, error) {
+	var updated T
+	if _, ok := columnField(reflect.ValueOf(updated), column); !ok {
+		return updated, fmt.Errorf("%s: unknown column %s", p.table, column)
+	}
+	err := inTx(ctx, p.db, func(tx *sqlx.Tx) error {
+		current, err := p.lock(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(current); err != nil {
+				return err
+			}
+		}
+		query := fmt.Sprintf(
+			"UPDATE %s SET %s WHERE id = $2 RETURNING *",
+			p.table,
+			versionedSet(current, column+" = $1"),
+		)
+		if err := tx.GetContext(ctx, &updated, query, value, id); err != nil {
+			return constraint(err)
+		}
+		return p.audit(ctx, tx, AuditUpdate, id, current, updated)
+	})
+	return updated, err
+}
+
+func (p *PostgresRepository[T]) Delete(ctx context.Context, id int, check func(current T) error) error {
+	return inTx(ctx, p.db, func(tx *sqlx.Tx) error {
+		current, err := p.lock(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(current); err != nil {
+				return err
+			}
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", p.table)
+		// для типов с корзиной удаление мягкое: запись только помечается
+		if _, ok := interface{}(current).(SoftDeletable); ok {
+			query = fmt.Sprintf("UPDATE %s SET deleted_at = now() WHERE id = $1", p.table)
+		}
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return constraint(err)
+		}
+		return p.audit(ctx, tx, AuditDelete, id, current, nil)
+	})
+}
+
+func (p *PostgresRepository[T]) Restore(ctx context.Context, id int) (T, error) {
+	var restored T
+	if _, ok := interface{}(restored).(SoftDeletable); !ok {
+		return restored, errNoTrash
+	}
+	err := inTx(ctx, p.db, func(tx *sqlx.Tx) error {
+		query := fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING *", p.table)
+		if err := tx.GetContext(ctx, &restored, query, id); err != nil {
+			return notFound(err)
+		}
+		return p.audit(ctx, tx, AuditRestore, id, nil, restored)
+	})
+	return restored, err
+}
+
+// Purge удаляет записи по одной: запись, на которую ещё ссылаются другие таблицы
+// (объект с продажей, продажа с выплатами или документами), остаётся в корзине,
+// а остальные удаляются.
+func (p *PostgresRepository[T]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var item T
+	if _, ok := interface{}(item).(SoftDeletable); !ok {
+		return 0, errNoTrash
+	}
+	conn := p.conn(ctx)
+	var ids []int
+	query := fmt.Sprintf("SELECT id FROM %s WHERE deleted_at IS NOT NULL AND deleted_at < $1 ORDER BY id", p.table)
+	if err := sqlx.SelectContext(ctx, conn, &ids, query, before); err != nil {
+		return 0, fmt.Errorf("purge %s: %v", p.table, err)
+	}
+	// внутри транзакции ошибка прерывает её целиком, поэтому каждую строку — в точке сохранения
+	_, nested := conn.(*sqlx.Tx)
+	var n int64
+	for _, id := range ids {
+		if nested {
+			if _, err := conn.ExecContext(ctx, "SAVEPOINT purge_row"); err != nil {
+				return n, fmt.Errorf("purge %s: %v", p.table, err)
+			}
+		}
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", p.table), id)
+		if err = constraint(err); errors.Is(err, ErrConstraint) {
+			log.Printf("purge %s %d skipped: %v", p.table, id, err)
+			if nested {
+				if _, err := conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT purge_row"); err != nil {
+					return n, fmt.Errorf("purge %s: %v", p.table, err)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("purge %s: %v", p.table, err)
+		}
+		if nested {
+			if _, err := conn.ExecContext(ctx, "RELEASE SAVEPOINT purge_row"); err != nil {
+				return n, fmt.Errorf("purge %s: %v", p.table, err)
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// conn — транзакция из контекста (см. PostgresTransactor) или пул.
+func (p *PostgresRepository[T]) conn(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := ctx.Value(txKey).(*sqlx.Tx); ok {
+		return tx
+	}
+	return p.db
+}
+
+// lock читает запись с блокировкой строки до конца транзакции.
+func (p *PostgresRepository[T]) lock(ctx context.Context, tx *sqlx.Tx, id int) (T, error) {
+	var current T
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1%s FOR UPDATE", p.table, andNotDeleted(current))
+	err := tx.GetContext(ctx, &current, query, id)
+	return current, notFound(err)
+}
+
+// audit пишет запись журнала в той же транзакции, что и само изменение.
+func (p *PostgresRepository[T]) audit(ctx context.Context, tx *sqlx.Tx, action string, id int, before, after interface{}) error {
+	a := actorFrom(ctx)
+	return RecordAudit(tx, a.UserID, a.IP, action, p.table, id, before, after)
+}
+
+// PostgresTransactor — Transactor поверх пула: транзакция передаётся хранилищам
+// через контекст, и их методы выполняются в ней, а не в собственной.
+type PostgresTransactor struct {
+	db *sqlx.DB
+}
+
+func NewPostgresTransactor(db *sqlx.DB) *PostgresTransactor {
+	return &PostgresTransactor{db: db}
+}
+
+func (t *PostgresTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return inTx(ctx, t.db, func(tx *sqlx.Tx) error {
+		return fn(context.WithValue(ctx, txKey, tx))
+	})
+}
+
+// inTx выполняет fn в транзакции из контекста или, если её нет, в новой.
+func inTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	if tx, ok := ctx.Value(txKey).(*sqlx.Tx); ok {
+		return fn(tx)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// constraint помечает нарушения ограничений схемы (класс 23) как ErrConstraint,
+// а пересечения по EXCLUDE-ограничениям — как ErrConflict.
+func constraint(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23P01" {
+		return fmt.Errorf("%w: %s", ErrConflict, pqErr.Message)
+	}
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == "23" {
+		return fmt.Errorf("%w: %s", ErrConstraint, pqErr.Message)
+	}
+	return err
+}
+
+// sqlWhere переводит ListQuery в WHERE с позиционными параметрами.
+func (q *ListQuery) sqlWhere(item interface{}) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if _, ok := item.(SoftDeletable); ok {
+		if q.Trash {
+			where = append(where, "deleted_at IS NOT NULL")
+		} else {
+			where = append(where, "deleted_at IS NULL")
+		}
+	}
+	table := ""
+	if h, ok := item.(Helper); ok {
+		table = h.GetNameTable()
+	}
+	var target string
+	for _, c := range q.Conditions {
+		if c.Converted {
+			// обе суммы приводим к базовой валюте: сумма записи × её курс против
+			// суммы из запроса × курс валюты запроса
+			if target == "" {
+				target = arg(q.Currency)
+			}
+			where = append(where, fmt.Sprintf("%s %s %s * %s",
+				convertedSQL(table, c.Column), c.Op, arg(c.Value), rateSQL(target)))
+			continue
+		}
+		if c.Op == OpIn {
+			where = append(where, fmt.Sprintf("%s = ANY(%s)", c.Column, arg(pq.Array(c.Value))))
+			continue
+		}
+		where = append(where, fmt.Sprintf("%s %s %s", c.Column, c.Op, arg(c.Value)))
+	}
+	for _, col := range q.NotNull {
+		where = append(where, col+" IS NOT NULL")
+	}
+	if r := q.Radius; r != nil {
+		lat, lng, km := arg(r.Lat), arg(r.Lng), arg(r.Km)
+		// least(1, ...) — из-за округления у почти противоположных точек корень
+		// чуть больше 1, и asin падает с «input is out of range»
+		where = append(where, fmt.Sprintf(
+			"%.1f * 2 * asin(least(1, sqrt(power(sin(radians(%s - %s) / 2), 2) + "+
+				"cos(radians(%s)) * cos(radians(%s)) * power(sin(radians(%s - %s) / 2), 2)))) <= %s",
+			earthRadiusKm, r.LatColumn, lat, lat, r.LatColumn, r.LngColumn, lng, km,
+		))
+	}
+	if q.After != nil {
+		op := ">"
+		if q.SortDesc {
+			op = "<"
+		}
+		if q.SortColumn == "id" {
+			where = append(where, fmt.Sprintf("id %s %s", op, arg(q.After.ID)))
+		} else {
+			v, id := arg(q.After.Value), arg(q.After.ID)
+			where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", q.SortColumn, op, v, id))
+		}
+	}
+
+	if len(where) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+func (q *ListQuery) orderSQL(table string) string {
+	dir := "ASC"
+	if q.SortDesc {
+		dir = "DESC"
+	}
+	if q.SortColumn == "" || q.SortColumn == "id" {
+		return "id " + dir
+	}
+	if q.SortConverted {
+		return fmt.Sprintf("%s %s, id %s", convertedSQL(table, q.SortColumn), dir, dir)
+	}
+	return fmt.Sprintf("%s %s, id %s", q.SortColumn, dir, dir)
+}
+
+// rateSQL — курс валюты currency к базовой из exchange_rates; у базовой — 1,
+// у валюты без курса — NULL, и такая сумма ни с чем не сравнивается.
+func rateSQL(currency string) string {
+	return fmt.Sprintf("(CASE WHEN %s = '%s' THEN 1 ELSE (SELECT rate FROM exchange_rates WHERE exchange_rates.currency = %s) END)",
+		currency, BaseCurrency, currency)
+}
+
+// convertedSQL — сумма из колонки column, приведённая к базовой валюте по валюте записи.
+func convertedSQL(table, column string) string {
+	return fmt.Sprintf("%s.%s * %s", table, column, rateSQL(table+".currency"))
+}
@@ -0,0 +1,223 @@
+package estate
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+	dateLayout   = "2006-01-02"
+)
+
+// columnMapper находит поле структуры по имени колонки (тег db), в том числе во встроенных структурах.
+var columnMapper = reflectx.NewMapper("db")
+
+// Типы значений фильтра — по ним проверяем параметр до того, как он попадёт в SQL.
+// KindDecimal — денежная сумма: при ?currency= сравнивается после пересчёта в эту валюту.
+const (
+	KindInt     = "int"
+	KindFloat   = "float"
+	KindDecimal = "decimal"
+	KindString  = "string"
+	KindTime    = "time"
+)
+
+// Filter описывает query-параметр списка: к какой колонке он относится,
+// каким оператором сравнивается и какого типа значение ожидается.
+type Filter struct {
+	Column string
+	Op     string
+	Kind   string
+}
+
+// ListResponse — ответ списочных эндпоинтов с общим количеством и ссылками на соседние страницы.
+type ListResponse[T Helper] struct {
+	Items  []T    `json:"items"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+}
+
+// parseListQuery разбирает фильтры, сортировку и пагинацию по описанию колонок из Helper.
+// Записи из корзины в выборку не попадают.
+func parseListQuery(item Helper, values url.Values) (*ListQuery, error) {
+	return parseScopedListQuery(item, values, false)
+}
+
+// parseScopedListQuery — parseListQuery с выбором области: обычные записи или корзина.
+func parseScopedListQuery(item Helper, values url.Values, trash bool) (*ListQuery, error) {
+	q := &ListQuery{SortColumn: "id", Limit: defaultLimit, Trash: trash}
+	if raw := values.Get("currency"); raw != "" {
+		c := Currency(strings.ToUpper(raw))
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		q.Currency = c
+	}
+
+	filters := item.GetFilters()
+	for param, f := range filters {
+		raw := values.Get(param)
+		if raw == "" {
+			continue
+		}
+		v, err := parseFilterValue(f.Kind, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", param, err)
+		}
+		op := f.Op
+		if f.Kind == KindTime && op == "<=" && isDateOnly(raw) {
+			// «по 31 мая» включает весь день: < 1 июня
+			op, v = "<", v.(time.Time).AddDate(0, 0, 1)
+		}
+		cond := Condition{Column: f.Column, Op: op, Value: v}
+		// сумма из запроса — в валюте ?currency=, суммы записей пересчитываются в неё
+		cond.Converted = f.Kind == KindDecimal && q.Currency != ""
+		q.Conditions = append(q.Conditions, cond)
+	}
+	if g, ok := item.(GeoLocated); ok {
+		if err := q.addGeoFilters(g, values); err != nil {
+			return nil, err
+		}
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		col := sort
+		if strings.HasPrefix(sort, "-") {
+			q.SortDesc = true
+			col = sort[1:]
+		}
+		if !containsColumn(item.GetSortColumns(), col) {
+			return nil, fmt.Errorf("sort not allowed: %s", col)
+		}
+		q.SortColumn = col
+		q.SortConverted = q.Currency != "" && isAmountColumn(filters, col)
+	}
+
+	if values.Has("cursor") {
+		if values.Has("offset") {
+			return nil, fmt.Errorf("cursor and offset cannot be combined")
+		}
+		if q.SortConverted {
+			// ключ курсора — сумма в валюте записи, а порядок — по пересчитанной
+			return nil, fmt.Errorf("cursor cannot be combined with sort by %s in another currency", q.SortColumn)
+		}
+		q.useCursor = true
+		q.NoTotal = true
+		q.cursor = values.Get("cursor")
+		// без явной сортировки листаем по времени создания, если оно есть у таблицы
+		if values.Get("sort") == "" && containsColumn(item.GetSortColumns(), "created_at") {
+			q.SortColumn = "created_at"
+		}
+	}
+
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit: %s", s)
+		}
+		if n > maxLimit {
+			n = maxLimit
+		}
+		q.Limit = n
+	}
+	if s := values.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid offset: %s", s)
+		}
+		q.Offset = n
+	}
+	return q, nil
+}
+
+func parseFilterValue(kind, raw string) (interface{}, error) {
+	switch kind {
+	case KindInt:
+		return strconv.Atoi(raw)
+	case KindFloat:
+		return strconv.ParseFloat(raw, 64)
+	case KindDecimal:
+		return ParseAmount(raw)
+	case KindTime:
+		// принимаем как дату, так и полный RFC3339
+		if t, err := time.Parse(dateLayout, raw); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.RFC3339, raw)
+	default:
+		return raw, nil
+	}
+}
+
+// isDateOnly — значение задано датой без времени.
+func isDateOnly(raw string) bool {
+	_, err := time.Parse(dateLayout, raw)
+	return err == nil
+}
+
+// isAmountColumn — по колонке есть денежный фильтр, то есть в ней сумма.
+func isAmountColumn(filters map[string]Filter, col string) bool {
+	for _, f := range filters {
+		if f.Column == col && f.Kind == KindDecimal {
+			return true
+		}
+	}
+	return false
+}
+
+func containsColumn(cols []string, col string) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// converts — выборке нужен пересчёт сумм в валюту q.Currency.
+func (q *ListQuery) converts() bool {
+	if q.SortConverted {
+		return true
+	}
+	for _, c := range q.Conditions {
+		if c.Converted {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *ListQuery) addCondition(column, op string, value interface{}) {
+	q.Conditions = append(q.Conditions, Condition{Column: column, Op: op, Value: value})
+}
+
+// pageLinks строит ссылки на следующую и предыдущую страницу, сохраняя остальные параметры запроса.
+func (q *ListQuery) pageLinks(u *url.URL, total int) (next, prev string) {
+	link := func(offset int) string {
+		values := u.Query()
+		values.Set("limit", strconv.Itoa(q.Limit))
+		values.Set("offset", strconv.Itoa(offset))
+		return u.Path + "?" + values.Encode()
+	}
+	if q.Offset+q.Limit < total {
+		next = link(q.Offset + q.Limit)
+	}
+	if q.Offset > 0 {
+		p := q.Offset - q.Limit
+		if p < 0 {
+			p = 0
+		}
+		prev = link(p)
+	}
+	return next, prev
+}
@@ -0,0 +1,296 @@
+package estate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrNotFound — записи нет или она в корзине.
+	ErrNotFound = errors.New("not found")
+	// ErrConstraint — хранилище отвергло данные (внешний ключ, уникальность и т.п.).
+	ErrConstraint = errors.New("constraint violation")
+	// ErrConflict — операция противоречит текущему состоянию записи.
+	ErrConflict = errors.New("conflict")
+	// errNoTrash — корзина запрошена у типа без deleted_at.
+	errNoTrash = errors.New("resource has no trash")
+	// errNoTx — Lock вызван без транзакции в контексте: блокировка снялась бы сразу.
+	errNoTx = errors.New("lock requires a transaction")
+)
+
+// Repository — хранилище записей одного Helper-типа. Обработчики работают только
+// через него, поэтому API поднимается как на Postgres, так и целиком в памяти.
+// Изменяющие методы атомарны и пишут журнал изменений от имени Actor из контекста.
+type Repository[T Helper] interface {
+	// List возвращает страницу выборки и общее количество (-1, если q.NoTotal).
+	List(ctx context.Context, q *ListQuery) ([]T, int, error)
+	// ListByOwner — List только по записям владельца.
+	ListByOwner(ctx context.Context, ownerID int, q *ListQuery) ([]T, int, error)
+	// Each обходит выборку построчно, не собирая её в память.
+	Each(ctx context.Context, q *ListQuery, fn func(T) error) error
+	Get(ctx context.Context, id int) (T, error)
+	// Lock — Get с блокировкой записи до конца транзакции из контекста: так
+	// параллельные изменения, связанные с одной записью, выполняются по очереди.
+	// Без транзакции в контексте возвращает errNoTx.
+	Lock(ctx context.Context, id int) (T, error)
+	// Insert сохраняет записи с указанным владельцем одной транзакцией.
+	Insert(ctx context.Context, ownerID int, items ...T) ([]T, error)
+	// Update блокирует запись, передаёт её в mutate и сохраняет результат.
+	// Ошибка из mutate отменяет изменение и возвращается как есть.
+	Update(ctx context.Context, id int, mutate func(current T) (T, error)) (T, error)
+	// SetColumn блокирует запись, вызывает check (если задан) и меняет одну колонку.
+	// Так меняются колонки, которых нет в GetNameColumns: owner_id, status объекта.
+	SetColumn(ctx context.Context, id int, column string, value interface{}, check func(current T) error) (T, error)
+	// Delete блокирует запись, вызывает check (если задан) и удаляет её;
+	// для SoftDeletable-типов запись только переносится в корзину.
+	Delete(ctx context.Context, id int, check func(current T) error) error
+	// Restore возвращает запись из корзины.
+	Restore(ctx context.Context, id int) (T, error)
+	// Purge окончательно удаляет записи, попавшие в корзину раньше before.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Transactor выполняет fn атомарно: изменения всех хранилищ, вызванных
+// с переданным в fn контекстом, применяются вместе или не применяются вовсе.
+// Вложенный InTx выполняется в уже открытой транзакции.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Condition — сравнение колонки со значением: column op value.
+type Condition struct {
+	Column string
+	// Op — оператор сравнения; OpIn — колонка равна одному из значений среза Value.
+	Op    string
+	Value interface{}
+	// Converted — Value задан в валюте ListQuery.Currency, а сумма в колонке
+	// сравнивается после пересчёта из валюты записи по exchange_rates.
+	Converted bool
+}
+
+// OpIn — Condition.Op «одно из значений»; Value — срез.
+const OpIn = "IN"
+
+// Radius — «не дальше Km километров от точки» по колонкам координат.
+type Radius struct {
+	LatColumn string
+	LngColumn string
+	Lat       float64
+	Lng       float64
+	Km        float64
+}
+
+// Keyset — позиция последней отданной строки в cursor-режиме.
+type Keyset struct {
+	Value interface{}
+	ID    int
+}
+
+// ListQuery — выборка, не привязанная к SQL: её одинаково исполняют
+// Postgres и хранилище в памяти.
+type ListQuery struct {
+	Conditions []Condition
+	NotNull    []string
+	Radius     *Radius
+	// Trash — выбирать записи из корзины вместо обычных (для SoftDeletable-типов).
+	Trash      bool
+	SortColumn string
+	SortDesc   bool
+	// Currency — валюта из ?currency=; в неё пересчитываются суммы для условий
+	// Converted и для сортировки с SortConverted (тогда — по пересчитанной сумме).
+	Currency      Currency
+	SortConverted bool
+	After         *Keyset
+	// Limit 0 — без ограничения.
+	Limit   int
+	Offset  int
+	NoTotal bool
+
+	// cursor-режим: включается параметром cursor (пустое значение — первая страница)
+	useCursor bool
+	cursor    string
+}
+
+// withOwner возвращает копию выборки, ограниченную записями владельца.
+func (q *ListQuery) withOwner(ownerID int) *ListQuery {
+	c := *q
+	c.Conditions = append(append([]Condition{}, q.Conditions...), Condition{Column: "owner_id", Op: "=", Value: ownerID})
+	return &c
+}
+
+// Actor — кто вносит изменение; попадает в audit_log.
+type Actor struct {
+	UserID *int
+	IP     string
+}
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	auditActionKey
+	txKey
+	roleKey
+	converterKey
+)
+
+// WithActor кладёт в контекст автора изменений для журнала.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey, a)
+}
+
+func actorFrom(ctx context.Context) Actor {
+	a, _ := ctx.Value(actorKey).(Actor)
+	return a
+}
+
+// withRole кладёт в контекст роль пользователя, проверенную Access.
+func withRole(ctx context.Context, role int) context.Context {
+	return context.WithValue(ctx, roleKey, role)
+}
+
+func roleFrom(ctx context.Context) (int, bool) {
+	role, ok := ctx.Value(roleKey).(int)
+	return role, ok
+}
+
+// WithAuditAction подменяет действие в журнале для вставок (например, AuditImport вместо AuditCreate).
+func WithAuditAction(ctx context.Context, action string) context.Context {
+	return context.WithValue(ctx, auditActionKey, action)
+}
+
+func auditAction(ctx context.Context, fallback string) string {
+	if a, ok := ctx.Value(auditActionKey).(string); ok {
+		return a
+	}
+	return fallback
+}
+
+// Purger — то, что умеет чистить свою корзину; Repository подходит.
+type Purger interface {
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Repositories — все хранилища API; собираются один раз при старте.
+type Repositories struct {
+	Tx              Transactor
+	Properties      Repository[Property]
+	Photos          Repository[PropertyPhoto]
+	Favorites       Repository[Favorite]
+	SavedSearches   Repository[SavedSearch]
+	Notifications   Repository[Notification]
+	ListingChanges  Repository[ListingChange]
+	Transitions     Repository[PropertyTransition]
+	Reservations    Repository[Reservation]
+	Viewings        Repository[Viewing]
+	Offers          Repository[Offer]
+	CommissionPlans Repository[CommissionPlan]
+	Commissions     Repository[Commission]
+	Templates       Repository[DocumentTemplate]
+	Documents       Repository[SaleDocument]
+	DealDocuments   Repository[DealDocument]
+	DealVersions    Repository[DealDocumentVersion]
+	Rates           Repository[ExchangeRate]
+	Purchases       Repository[Purchase]
+	Sales           Repository[Sale]
+	Users           Repository[User]
+	Audit           Repository[AuditEntry]
+	Search          PropertySearcher
+}
+
+// NewPostgresRepositories — хранилища поверх одного пула соединений.
+func NewPostgresRepositories(db *sqlx.DB) *Repositories {
+	tx := NewPostgresTransactor(db)
+	searches := NewPostgresRepository[SavedSearch](db)
+	notifications := NewPostgresRepository[Notification](db)
+	rates := NewPostgresRepository[ExchangeRate](db)
+	transitions := NewPostgresRepository[PropertyTransition](db)
+	changes := NewPostgresRepository[ListingChange](db)
+	commissions := NewPostgresRepository[Commission](db)
+	documents := NewPostgresRepository[SaleDocument](db)
+	return &Repositories{
+		Tx:              tx,
+		Properties:      watchSearches(NewPostgresRepository[Property](db), tx, transitions, changes),
+		Photos:          NewPostgresRepository[PropertyPhoto](db),
+		Favorites:       NewPostgresRepository[Favorite](db),
+		SavedSearches:   searches,
+		Notifications:   notifications,
+		ListingChanges:  changes,
+		Transitions:     transitions,
+		Reservations:    NewPostgresRepository[Reservation](db),
+		Viewings:        NewPostgresRepository[Viewing](db),
+		Offers:          NewPostgresRepository[Offer](db),
+		CommissionPlans: NewPostgresRepository[CommissionPlan](db),
+		Commissions:     commissions,
+		Templates:       NewPostgresRepository[DocumentTemplate](db),
+		Documents:       documents,
+		DealDocuments:   NewPostgresRepository[DealDocument](db),
+		DealVersions:    NewPostgresRepository[DealDocumentVersion](db),
+		Rates:           rates,
+		Purchases:       NewPostgresRepository[Purchase](db),
+		Sales:           protectSales(NewPostgresRepository[Sale](db), commissions, documents),
+		Users:           NewPostgresRepository[User](db),
+		Audit:           NewPostgresRepository[AuditEntry](db),
+		Search:          NewPostgresSearch(db),
+	}
+}
+
+// NewMemoryRepositories — хранилища в памяти с общим журналом изменений; для тестов.
+func NewMemoryRepositories() *Repositories {
+	audit := NewMemoryRepository[AuditEntry](nil)
+	properties := NewMemoryRepository[Property](audit)
+	photos := NewMemoryRepository[PropertyPhoto](audit)
+	favorites := NewMemoryRepository[Favorite](audit)
+	searches := NewMemoryRepository[SavedSearch](audit)
+	notifications := NewMemoryRepository[Notification](audit)
+	changes := NewMemoryRepository[ListingChange](audit)
+	transitions := NewMemoryRepository[PropertyTransition](audit)
+	reservations := NewMemoryRepository[Reservation](audit)
+	viewings := NewMemoryRepository[Viewing](audit)
+	offers := NewMemoryRepository[Offer](audit)
+	plans := NewMemoryRepository[CommissionPlan](audit)
+	commissions := NewMemoryRepository[Commission](audit)
+	templates := NewMemoryRepository[DocumentTemplate](audit)
+	documents := NewMemoryRepository[SaleDocument](audit)
+	dealDocuments := NewMemoryRepository[DealDocument](audit)
+	dealVersions := NewMemoryRepository[DealDocumentVersion](audit)
+	rates := NewMemoryRepository[ExchangeRate](audit)
+	purchases := NewMemoryRepository[Purchase](audit)
+	sales := NewMemoryRepository[Sale](audit)
+	users := NewMemoryRepository[User](audit)
+	tx := NewMemoryTransactor(audit, properties, photos, favorites, searches, notifications, changes, transitions, reservations, viewings, offers, plans, commissions, templates, documents, dealDocuments, dealVersions, rates, purchases, sales, users)
+	return &Repositories{
+		Tx:              tx,
+		Properties:      watchSearches(properties, tx, transitions, changes),
+		Photos:          photos,
+		Favorites:       favorites,
+		SavedSearches:   searches,
+		Notifications:   notifications,
+		ListingChanges:  changes,
+		Transitions:     transitions,
+		Reservations:    reservations,
+		Viewings:        viewings,
+		Offers:          offers,
+		CommissionPlans: plans,
+		Commissions:     commissions,
+		Templates:       templates,
+		Documents:       documents,
+		DealDocuments:   dealDocuments,
+		DealVersions:    dealVersions,
+		Rates:           rates,
+		Purchases:       purchases,
+		Sales:           protectSales(sales, commissions, documents),
+		Users:           users,
+		Audit:           audit,
+		Search:          NewMemorySearch(properties),
+	}
+}
+
+// TrashPurgers — корзины в порядке очистки: продажи и покупки раньше объектов,
+// чтобы не упереться во внешние ключи.
+func (r *Repositories) TrashPurgers() []Purger {
+	return []Purger{r.Sales, r.Purchases, r.Properties}
+}
@@ -0,0 +1,293 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/store"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Статусы брони. Действующая бронь держит объект в статусе reserved.
+const (
+	ReservationActive   = "active"
+	ReservationReleased = "released"
+	ReservationExpired  = "expired"
+)
+
+// Reservation — бронь объекта под задаток клиента до ExpiresAt.
+type Reservation struct {
+	ID         int        `json:"id" db:"id"`
+	PropertyID int        `json:"property_id" db:"property_id"`
+	ClientID   int        `json:"client_id" db:"client_id"`
+	Deposit    Amount     `json:"deposit" db:"deposit"`
+	Currency   Currency   `json:"currency" db:"currency"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	Status     string     `json:"status" db:"status"`
+	OwnerID    int        `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"-" db:"updated_at"`
+	Converted  *Converted `json:"converted,omitempty" db:"-"`
+}
+
+func (r Reservation) GetNameTable() string {
+	return "reservations"
+}
+func (r Reservation) GetNameColumns() string {
+	return "property_id, client_id, deposit, currency, expires_at"
+}
+func (r Reservation) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5"
+}
+func (r Reservation) GetValues() []interface{} {
+	return []interface{}{
+		r.PropertyID, r.ClientID, r.Deposit, r.Currency, r.ExpiresAt,
+	}
+}
+func (r Reservation) GetUpdatedAt() time.Time {
+	return r.UpdatedAt
+}
+func (r Reservation) Validate() error {
+	if r.PropertyID <= 0 {
+		return fmt.Errorf("property_id is required")
+	}
+	if r.ClientID <= 0 {
+		return fmt.Errorf("client_id is required")
+	}
+	if r.Deposit < 0 {
+		return fmt.Errorf("deposit must not be negative")
+	}
+	if err := r.Currency.Validate(); err != nil {
+		return err
+	}
+	if !r.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+func (r Reservation) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"property_id":  {Column: "property_id", Op: "=", Kind: KindInt},
+		"client_id":    {Column: "client_id", Op: "=", Kind: KindInt},
+		"status":       {Column: "status", Op: "=", Kind: KindString},
+		"owner_id":     {Column: "owner_id", Op: "=", Kind: KindInt},
+		"expires_from": {Column: "expires_at", Op: ">=", Kind: KindTime},
+		"expires_to":   {Column: "expires_at", Op: "<=", Kind: KindTime},
+	}
+}
+func (r Reservation) GetSortColumns() []string {
+	return []string{"id", "expires_at", "created_at"}
+}
+func (r Reservation) withConverted(c *Converter) Reservation {
+	r.Converted = convertFields(c, r.Currency, map[string]Amount{"deposit": r.Deposit})
+	return r
+}
+func (r Reservation) withDefaults() Reservation {
+	if r.Status == "" {
+		r.Status = ReservationActive
+	}
+	return r
+}
+
+// ReservationService ведёт брони вместе со статусом объекта: бронь переводит
+// объект в reserved, снятие и истечение брони возвращают его в available.
+type ReservationService struct {
+	tx           Transactor
+	lifecycle    *Lifecycle
+	reservations Repository[Reservation]
+}
+
+func NewReservationService(repos *Repositories) *ReservationService {
+	return &ReservationService{tx: repos.Tx, lifecycle: NewLifecycle(repos), reservations: repos.Reservations}
+}
+
+// Reserve бронирует объект от имени ownerID. Бронировать может владелец объекта
+// или администратор, иначе 403. Если объект не в available или у него уже есть
+// действующая бронь, возвращается ErrConflict.
+func (s *ReservationService) Reserve(ctx context.Context, res Reservation, ownerID int) (Reservation, error) {
+	role, _ := roleFrom(ctx)
+	var saved Reservation
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		// переход блокирует объект, поэтому две брони одного объекта не проходят параллельно
+		p, err := s.lifecycle.fire(ctx, res.PropertyID, EventReserve, func(current Property) error {
+			if current.OwnerID != ownerID && role != store.RoleAdmin {
+				return newStatusError(http.StatusForbidden, "only the owner of property %d can reserve it", current.ID)
+			}
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			return newStatusError(http.StatusUnprocessableEntity, "property %d not found", res.PropertyID)
+		}
+		if err != nil {
+			return err
+		}
+		q := &ListQuery{
+			Conditions: []Condition{
+				{Column: "property_id", Op: "=", Value: res.PropertyID},
+				{Column: "status", Op: "=", Value: ReservationActive},
+			},
+			Limit:   1,
+			NoTotal: true,
+		}
+		active, _, err := s.reservations.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		if len(active) > 0 {
+			return fmt.Errorf("%w: property %d already has an active reservation", ErrConflict, res.PropertyID)
+		}
+		if res.Currency == "" {
+			res.Currency = p.Currency
+		}
+		rows, err := s.reservations.Insert(ctx, ownerID, res)
+		if err != nil {
+			return err
+		}
+		saved = rows[0]
+		return nil
+	})
+	return saved, err
+}
+
+// Extend переносит окончание действующей брони на более поздний срок.
+func (s *ReservationService) Extend(ctx context.Context, id int, until time.Time) (Reservation, error) {
+	return s.reservations.SetColumn(ctx, id, "expires_at", until, func(current Reservation) error {
+		if current.Status != ReservationActive {
+			return fmt.Errorf("%w: reservation %d is %s", ErrConflict, id, current.Status)
+		}
+		if !until.After(current.ExpiresAt) {
+			return newStatusError(http.StatusUnprocessableEntity, "expires_at must be later than %s", current.ExpiresAt.Format(time.RFC3339))
+		}
+		return nil
+	})
+}
+
+// Release снимает действующую бронь и возвращает объект в продажу.
+func (s *ReservationService) Release(ctx context.Context, id int) (Reservation, error) {
+	return s.close(ctx, id, ReservationReleased, nil)
+}
+
+// Expire закрывает брони, срок которых истёк к now, и возвращает их количество.
+func (s *ReservationService) Expire(ctx context.Context, now time.Time) (int, error) {
+	q := &ListQuery{
+		Conditions: []Condition{
+			{Column: "status", Op: "=", Value: ReservationActive},
+			{Column: "expires_at", Op: "<=", Value: now},
+		},
+		NoTotal: true,
+	}
+	due, _, err := s.reservations.List(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, res := range due {
+		// бронь могли продлить или снять, пока шла выборка
+		_, err := s.close(ctx, res.ID, ReservationExpired, func(current Reservation) error {
+			if current.ExpiresAt.After(now) {
+				return fmt.Errorf("%w: reservation %d was extended", ErrConflict, current.ID)
+			}
+			return nil
+		})
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// close переводит действующую бронь в status и освобождает объект.
+func (s *ReservationService) close(ctx context.Context, id int, status string, check func(current Reservation) error) (Reservation, error) {
+	var closed Reservation
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		res, err := s.reservations.SetColumn(ctx, id, "status", status, func(current Reservation) error {
+			if current.Status != ReservationActive {
+				return fmt.Errorf("%w: reservation %d is %s", ErrConflict, id, current.Status)
+			}
+			if check != nil {
+				return check(current)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		closed = res
+		// объект мог уйти дальше по жизненному циклу (договор, продажа) или в корзину —
+		// тогда возвращать его в продажу не нужно
+		_, err = s.lifecycle.fire(ctx, res.PropertyID, EventRelease, nil)
+		if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+	return closed, err
+}
+
+// Create — POST /reservations.
+func (s *ReservationService) Create(w http.ResponseWriter, r *http.Request) {
+	var res Reservation
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := res.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ownerID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	saved, err := s.Reserve(requestContext(r), res, ownerID)
+	if err != nil {
+		writeRepoError(w, "Reserve", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// ExtendHandler — POST /reservations/{id}/extend с телом {"expires_at": "..."}.
+func (s *ReservationService) ExtendHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	res, err := s.Extend(requestContext(r), id, body.ExpiresAt)
+	if err != nil {
+		writeRepoError(w, "Extend", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// ReleaseHandler — POST /reservations/{id}/release.
+func (s *ReservationService) ReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	res, err := s.Release(requestContext(r), id)
+	if err != nil {
+		writeRepoError(w, "Release", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
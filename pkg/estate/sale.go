@@ -0,0 +1,199 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/store"
+	"fmt"
+	"net/http"
+)
+
+// SaleService оформляет продажу: запись в sales, переход sell, передача
+// объекта покупателю и начисление комиссий выполняются одной транзакцией.
+type SaleService struct {
+	tx          Transactor
+	lifecycle   *Lifecycle
+	commissions *CommissionService
+	properties  Repository[Property]
+	sales       Repository[Sale]
+	users       Repository[User]
+}
+
+func NewSaleService(repos *Repositories) *SaleService {
+	return &SaleService{
+		tx:          repos.Tx,
+		lifecycle:   NewLifecycle(repos),
+		commissions: NewCommissionService(repos),
+		properties:  repos.Properties,
+		sales:       repos.Sales,
+		users:       repos.Users,
+	}
+}
+
+// Register блокирует объект, выполняет переход sell, передаёт объект buyer_id,
+// сохраняет продажу от имени ownerID и начисляет комиссии по splits (без splits —
+// всё агенту, владевшему объектом; если владелец не агент, комиссия не начисляется).
+// Доли задаёт только администратор, получать их могут только агенты и
+// администраторы. Продать объект может его владелец или администратор, иначе 403.
+// Если объект продать нельзя (уже продан, снят, черновик), возвращается ErrConflict.
+func (s *SaleService) Register(ctx context.Context, sale Sale, ownerID int, splits ...CommissionSplit) (Sale, error) {
+	role, _ := roleFrom(ctx)
+	if len(splits) > 0 && role != store.RoleAdmin {
+		return Sale{}, newStatusError(http.StatusForbidden, "only admins can set commission splits")
+	}
+	if err := validateSplits(splits); err != nil {
+		return Sale{}, newStatusError(http.StatusUnprocessableEntity, "%s", err)
+	}
+	var saved Sale
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		for _, split := range splits {
+			ok, err := s.isAgent(ctx, split.AgentID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return newStatusError(http.StatusUnprocessableEntity, "splits: user %d is not an agent", split.AgentID)
+			}
+		}
+		listing, err := s.lifecycle.fire(ctx, sale.PropertyID, EventSell, func(current Property) error {
+			// продаёт владелец объекта; чужой объект — только администратор
+			if current.OwnerID != ownerID && role != store.RoleAdmin {
+				return newStatusError(http.StatusForbidden, "only the owner of property %d can sell it", current.ID)
+			}
+			if current.OwnerID == sale.BuyerID {
+				return fmt.Errorf("%w: buyer already owns property %d", ErrConflict, current.ID)
+			}
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			return newStatusError(http.StatusUnprocessableEntity, "property %d not found", sale.PropertyID)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.properties.SetColumn(ctx, sale.PropertyID, "owner_id", sale.BuyerID, nil); err != nil {
+			return err
+		}
+		if sale.Currency == "" {
+			sale.Currency = listing.Currency
+		}
+		rows, err := s.sales.Insert(ctx, ownerID, sale)
+		if err != nil {
+			return err
+		}
+		saved = rows[0]
+		if len(splits) == 0 {
+			ok, err := s.isAgent(ctx, listing.OwnerID)
+			if err != nil || !ok {
+				return err
+			}
+			splits = []CommissionSplit{{AgentID: listing.OwnerID, Share: 100}}
+		}
+		_, err = s.commissions.Accrue(ctx, saved, splits)
+		return err
+	})
+	return saved, err
+}
+
+// isAgent — пользователь с ролью агента или администратора; несуществующий — не агент.
+func (s *SaleService) isAgent(ctx context.Context, userID int) (bool, error) {
+	u, err := s.users.Get(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return containsInt(staffRoles, u.RoleID), nil
+}
+
+// Create — POST /sales: оформляет продажу через Register; 409, если объект продать нельзя.
+// Кроме полей Sale тело может содержать splits — доли агентов в комиссии (только администратор).
+func (s *SaleService) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Sale
+		Splits []CommissionSplit `json:"splits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := req.Sale.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ownerID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	saved, err := s.Register(requestContext(r), req.Sale, ownerID, req.Splits...)
+	if err != nil {
+		writeRepoError(w, "CreateSale", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// accruedSales — хранилище продаж, которое не отправляет в корзину продажу с
+// начислениями или выданными документами: это финансовая запись, из корзины её
+// всё равно не вычистить (commissions и sale_documents ссылаются на неё с
+// ON DELETE RESTRICT), а балансы агентов разошлись бы с продажами.
+type accruedSales struct {
+	Repository[Sale]
+	commissions Repository[Commission]
+	documents   Repository[SaleDocument]
+}
+
+func protectSales(sales Repository[Sale], commissions Repository[Commission], documents Repository[SaleDocument]) Repository[Sale] {
+	return &accruedSales{Repository: sales, commissions: commissions, documents: documents}
+}
+
+func (s *accruedSales) Delete(ctx context.Context, id int, check func(current Sale) error) error {
+	return s.Repository.Delete(ctx, id, func(current Sale) error {
+		if check != nil {
+			if err := check(current); err != nil {
+				return err
+			}
+		}
+		return s.inUse(ctx, id)
+	})
+}
+
+// Update не даёт перенести продажу на другой объект или другого покупателя:
+// по ним уже закрыт объект и начислены комиссии.
+func (s *accruedSales) Update(ctx context.Context, id int, mutate func(current Sale) (Sale, error)) (Sale, error) {
+	return s.Repository.Update(ctx, id, func(current Sale) (Sale, error) {
+		next, err := mutate(current)
+		if err != nil {
+			return next, err
+		}
+		if next.PropertyID != current.PropertyID || next.BuyerID != current.BuyerID {
+			return current, newStatusError(http.StatusUnprocessableEntity, "property_id and buyer_id cannot be changed")
+		}
+		return next, nil
+	})
+}
+
+// inUse — ErrConflict, если по продаже есть начисления или выданные документы.
+func (s *accruedSales) inUse(ctx context.Context, saleID int) error {
+	q := &ListQuery{Conditions: []Condition{{Column: "sale_id", Op: "=", Value: saleID}}, Limit: 1, NoTotal: true}
+	accrued, _, err := s.commissions.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	if len(accrued) > 0 {
+		return fmt.Errorf("%w: sale %d has commissions", ErrConflict, saleID)
+	}
+	issued, _, err := s.documents.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	if len(issued) > 0 {
+		return fmt.Errorf("%w: sale %d has issued documents", ErrConflict, saleID)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package estate
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// searchDocument — tsvector по текстовым полям объекта; совпадает с выражением индекса из миграции 0002_property_search.
+const searchDocument = "to_tsvector('simple', coalesce(address, '') || ' ' || coalesce(type, ''))"
+
+// Границы совпадения в сыром фрагменте: управляющие символы, которых нет в
+// экранированном HTML, поэтому разметку добавляем уже после экранирования.
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+var (
+	snippetMarks = strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>")
+	snippetStrip = strings.NewReplacer(snippetStart, "", snippetStop, "")
+)
+
+// renderSnippet экранирует фрагмент и заменяет границы совпадений на <mark>.
+func renderSnippet(raw string) string {
+	return snippetMarks.Replace(html.EscapeString(raw))
+}
+
+// SearchResult — объект недвижимости с релевантностью и подсвеченным фрагментом.
+// Snippet — готовый HTML: текст экранирован, совпадения обёрнуты в <mark>.
+type SearchResult struct {
+	Property
+	Rank    float64 `json:"rank" db:"rank"`
+	Snippet string  `json:"snippet" db:"snippet"`
+}
+
+func (s SearchResult) withConverted(c *Converter) SearchResult {
+	s.Property = s.Property.withConverted(c)
+	return s
+}
+
+// PropertySearcher — полнотекстовый поиск объектов с фильтрами и пагинацией ListQuery.
+type PropertySearcher interface {
+	Search(ctx context.Context, text string, q *ListQuery) ([]SearchResult, int, error)
+}
+
+// SearchProperties ищет объекты по q: полнотекстовый поиск по адресу и типу
+// плюс триграммное сходство, чтобы находить адреса с опечатками.
+// Фильтры и пагинация — те же, что у Read[Property].
+func SearchProperties(s PropertySearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		text := strings.TrimSpace(r.URL.Query().Get("q"))
+		if text == "" {
+			http.Error(w, "Query parameter q is required", http.StatusBadRequest)
+			return
+		}
+		lq, err := parseListQuery(Property{}, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if lq.useCursor {
+			http.Error(w, "cursor is not supported for search", http.StatusBadRequest)
+			return
+		}
+		result, total, err := s.Search(r.Context(), text, lq)
+		if err != nil {
+			log.Println("SearchProperties error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		convertAll(r.Context(), result)
+		next, prev := lq.pageLinks(r.URL, total)
+		writeJSON(w, http.StatusOK, ListResponse[SearchResult]{
+			Items:  result,
+			Total:  total,
+			Limit:  lq.Limit,
+			Offset: lq.Offset,
+			Next:   next,
+			Prev:   prev,
+		})
+	}
+}
+
+// PostgresSearch — поиск на tsvector и pg_trgm (миграция 0002_property_search).
+type PostgresSearch struct {
+	db *sqlx.DB
+}
+
+func NewPostgresSearch(db *sqlx.DB) *PostgresSearch {
+	return &PostgresSearch{db: db}
+}
+
+func (s *PostgresSearch) Search(ctx context.Context, text string, q *ListQuery) ([]SearchResult, int, error) {
+	var item Property
+	where, args := q.sqlWhere(item)
+	args = append(args, text)
+	n := len(args)
+	tsQuery := fmt.Sprintf("websearch_to_tsquery('simple', $%d)", n)
+	match := fmt.Sprintf("(%s @@ %s OR $%d <%% address)", searchDocument, tsQuery, n)
+	if where == "" {
+		where = " WHERE " + match
+	} else {
+		where += " AND " + match
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", item.GetNameTable(), where)
+	if err := s.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(
+		`SELECT *,
+			ts_rank(%s, %s) + word_similarity($%d, address) AS rank,
+			ts_headline('simple', translate(coalesce(address, '') || ' ' || coalesce(type, ''), chr(2) || chr(3), ''), %s,
+				'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', HighlightAll=true') AS snippet
+		FROM %s%s ORDER BY rank DESC, id ASC LIMIT %d OFFSET %d`,
+		searchDocument, tsQuery, n,
+		tsQuery,
+		item.GetNameTable(),
+		where,
+		q.Limit,
+		q.Offset,
+	)
+	result := []SearchResult{}
+	if err := s.db.SelectContext(ctx, &result, query, args...); err != nil {
+		return nil, 0, err
+	}
+	for i := range result {
+		result[i].Snippet = renderSnippet(result[i].Snippet)
+	}
+	return result, total, nil
+}
+
+// MemorySearch — упрощённый поиск для MemoryRepository: объект подходит, если
+// в адресе или типе встречается хотя бы одно слово запроса (без учёта регистра);
+// релевантность — доля найденных слов.
+type MemorySearch struct {
+	repo *MemoryRepository[Property]
+}
+
+func NewMemorySearch(repo *MemoryRepository[Property]) *MemorySearch {
+	return &MemorySearch{repo: repo}
+}
+
+func (s *MemorySearch) Search(ctx context.Context, text string, q *ListQuery) ([]SearchResult, int, error) {
+	words := strings.Fields(strings.ToLower(text))
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	highlight := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	all := *q
+	all.Limit, all.Offset = 0, 0
+	rows, _, err := s.repo.List(ctx, &all)
+	if err != nil {
+		return nil, 0, err
+	}
+	found := []SearchResult{}
+	for _, p := range rows {
+		doc := snippetStrip.Replace(p.Address + " " + p.Type)
+		lower := strings.ToLower(doc)
+		matched := 0
+		for _, word := range words {
+			if strings.Contains(lower, word) {
+				matched++
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+		found = append(found, SearchResult{
+			Property: p,
+			Rank:     float64(matched) / float64(len(words)),
+			Snippet:  renderSnippet(highlight.ReplaceAllString(doc, snippetStart+"$0"+snippetStop)),
+		})
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Rank != found[j].Rank {
+			return found[i].Rank > found[j].Rank
+		}
+		return found[i].ID < found[j].ID
+	})
+
+	total := len(found)
+	if q.Offset >= total {
+		return []SearchResult{}, total, nil
+	}
+	found = found[q.Offset:]
+	if q.Limit > 0 && len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found, total, nil
+}
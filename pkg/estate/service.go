@@ -2,59 +2,146 @@
 package estate
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"example-app/pkg/store"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"reflect"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
 )
 
-var DB *sqlx.DB
-
 var AllowedTables = map[string]bool{
-	"properties": true,
-	"purchases":  true,
-	"sales":      true,
-	"users":      true,
+	"properties":             true,
+	"property_photos":        true,
+	"favorites":              true,
+	"saved_searches":         true,
+	"notifications":          true,
+	"listing_changes":        true,
+	"property_transitions":   true,
+	"reservations":           true,
+	"viewings":               true,
+	"offers":                 true,
+	"commission_plans":       true,
+	"document_templates":     true,
+	"sale_documents":         true,
+	"deal_documents":         true,
+	"deal_document_versions": true,
+	"exchange_rates":         true,
+	"commissions":            true,
+	"purchases":              true,
+	"sales":                  true,
+	"users":                  true,
+	"audit_log":              true,
 }
 
-func InitDB() error {
-	var err error
-	DB, err = sqlx.Connect("postgres", os.Getenv("CONNECT_SQL"))
-	if err != nil {
-		return fmt.Errorf("error connecting to database: %v", err)
+func isAllowedTable(name string) bool {
+	return AllowedTables[name]
+}
+
+// Handler — HTTP-обработчики ресурса T поверх его хранилища.
+type Handler[T Helper] struct {
+	repo Repository[T]
+}
+
+func NewHandler[T Helper](repo Repository[T]) *Handler[T] {
+	return &Handler[T]{repo: repo}
+}
+
+// listFunc — List или ListByOwner хранилища, уже привязанный к владельцу.
+type listFunc[T Helper] func(ctx context.Context, q *ListQuery) ([]T, int, error)
+
+// statusError — отказ бизнес-проверки с готовым HTTP-статусом. Его возвращают
+// колбэки Update/Delete: хранилище откатывает изменение, а обработчик отвечает клиенту.
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+func newStatusError(status int, format string, args ...interface{}) error {
+	return &statusError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// writeRepoError переводит ошибку хранилища в ответ; неизвестные ошибки логируются как 500.
+func writeRepoError(w http.ResponseWriter, op string, err error) {
+	var se *statusError
+	switch {
+	case errors.As(err, &se):
+		http.Error(w, se.msg, se.status)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Не найден!", http.StatusNotFound)
+	case errors.Is(err, errPreconditionFailed):
+		http.Error(w, "Запись была изменена", http.StatusPreconditionFailed)
+	case errors.Is(err, ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrConstraint):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errNoTrash):
+		http.Error(w, "Invalid resource", http.StatusBadRequest)
+	default:
+		log.Println(op+" error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
-	return nil
 }
 
-func isAllowedTable(name string) bool {
-	return AllowedTables[name]
+// recordID достаёт значение колонки id из записи любого Helper-типа.
+func recordID(item interface{}) int {
+	f, ok := columnField(reflect.ValueOf(item), "id")
+	if !ok {
+		return 0
+	}
+	return int(f.Int())
+}
+
+// urlID разбирает {id} из пути; при ошибке сам отвечает 400.
+func urlID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
 }
 
-func Create[T Helper](w http.ResponseWriter, r *http.Request) {
+func (h *Handler[T]) Create(w http.ResponseWriter, r *http.Request) {
 	var item T
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
+	if v, ok := interface{}(item).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
 
-	table := item.GetNameTable()
-	if !isAllowedTable(table) {
-		http.Error(w, "Invalid resource", http.StatusBadRequest)
+	ownerID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if _, err := h.repo.Insert(requestContext(r), ownerID, item); err != nil {
+		writeRepoError(w, "Create", err)
 		return
 	}
+	w.WriteHeader(http.StatusCreated)
+}
 
+// buildInsert собирает INSERT по колонкам Helper; owner_id — последний параметр.
+func buildInsert(item Helper) string {
 	cols := item.GetNameColumns()         // e.g. "address, type, price, status"
 	placeholders := item.GetPlaceholder() // e.g. "$1, $2, $3, $4"
-	values := item.GetValues()
 
 	// вычисляем следующий placeholder для owner_id
 	// считаем количество placeholders (количество запятых + 1)
@@ -64,184 +151,130 @@ func Create[T Helper](w http.ResponseWriter, r *http.Request) {
 	}
 	ownerPlaceholder := fmt.Sprintf("$%d", n+1)
 
-	query := fmt.Sprintf(
+	return fmt.Sprintf(
 		"INSERT INTO %s (%s, owner_id) VALUES (%s, %s)",
-		table,
+		item.GetNameTable(),
 		cols,
 		placeholders,
 		ownerPlaceholder,
 	)
+}
 
-	ownerID, err := store.GetIDUser(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-	args := append(values, ownerID)
-
-	_, err = DB.Exec(query, args...)
+func (h *Handler[T]) Read(w http.ResponseWriter, r *http.Request) {
+	var item T
+	lq, err := parseListQuery(item, r.URL.Query())
 	if err != nil {
-		log.Println("Create Exec error:", err)
-		http.Error(w, "Server Error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
+	writeList(w, r, lq, h.repo.List)
 }
 
-func Read[T Helper](w http.ResponseWriter, r *http.Request) {
-	var item T
-	table := item.GetNameTable()
-	if !isAllowedTable(table) {
-		http.Error(w, "Invalid resource", http.StatusBadRequest)
+// writeList выбирает страницу по ListQuery и отдаёт ListResponse (или CursorResponse).
+func writeList[T Helper](w http.ResponseWriter, r *http.Request, lq *ListQuery, list listFunc[T]) {
+	if lq.useCursor {
+		writeCursorList(w, r, lq, list)
 		return
 	}
-
-	query := fmt.Sprintf("SELECT * FROM %s", table)
-	result := []T{}
-	if err := DB.Select(&result, query); err != nil {
-		log.Println("Read Select error:", err)
+	result, total, err := list(r.Context(), lq)
+	if err != nil {
+		log.Println("List error:", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(result)
+	convertAll(r.Context(), result)
+	next, prev := lq.pageLinks(r.URL, total)
+	writeJSONWithETag(w, r, ListResponse[T]{
+		Items:  result,
+		Total:  total,
+		Limit:  lq.Limit,
+		Offset: lq.Offset,
+		Next:   next,
+		Prev:   prev,
+	})
 }
 
-func Update[T Helper](w http.ResponseWriter, r *http.Request) {
+func (h *Handler[T]) Update(w http.ResponseWriter, r *http.Request) {
 	var item T
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
-
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
-		return
-	}
-
-	table := item.GetNameTable()
-	if !isAllowedTable(table) {
-		http.Error(w, "Invalid resource", http.StatusBadRequest)
-		return
+	if v, ok := interface{}(item).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 	}
-
-	param := strings.Split(item.GetNameColumns(), ", ")
-	placeholder := strings.Split(item.GetPlaceholder(), ", ")
-	if len(param) != len(placeholder) {
-		http.Error(w, "Mismatch columns/placeholders", http.StatusInternalServerError)
+	id, ok := urlID(w, r)
+	if !ok {
 		return
 	}
 
-	setParam := ""
-	for i := 0; i < len(param); i++ {
-		setParam += param[i] + " = " + strings.TrimSpace(placeholder[i]) + ", "
-	}
-	setParam = strings.TrimSuffix(setParam, ", ")
-
-	// id placeholder должен быть следующим по номеру
-	idPlaceholder := fmt.Sprintf("$%d", len(placeholder)+1)
-
-	query := fmt.Sprintf(
-		"UPDATE %s SET %s WHERE id = %s",
-		table,
-		setParam,
-		idPlaceholder,
-	)
-
-	args := append(item.GetValues(), id)
-	_, err = DB.Exec(query, args...)
+	updated, err := h.repo.Update(requestContext(r), id, func(current T) (T, error) {
+		return item, checkIfMatch(r, current)
+	})
 	if err != nil {
-		log.Println("Update Exec error:", err)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		writeRepoError(w, "Update", err)
 		return
 	}
+	w.Header().Set("ETag", computeETag(updated))
 	w.WriteHeader(http.StatusOK)
 }
 
-func Delete[T Helper](w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+func (h *Handler[T]) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
 		return
 	}
-	var item T
-	table := item.GetNameTable()
-	if !isAllowedTable(table) {
-		http.Error(w, "Invalid resource", http.StatusBadRequest)
-		return
-	}
-	query := fmt.Sprintf(
-		"DELETE FROM %s WHERE id = $1",
-		table,
-	)
-	result, err := DB.Exec(query, id)
+	err := h.repo.Delete(requestContext(r), id, func(current T) error {
+		return checkIfMatch(r, current)
+	})
 	if err != nil {
-		log.Println("Delete Exec error:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-	if affected, _ := result.RowsAffected(); affected == 0 {
-		http.Error(w, "Not found", http.StatusNotFound)
+		writeRepoError(w, "Delete", err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Операция завершилась успешно!"})
 }
 
-func GetByID[T Helper](w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+func (h *Handler[T]) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
 		return
 	}
-	var item T
-	table := item.GetNameTable()
-	if !isAllowedTable(table) {
-		http.Error(w, "Invalid resource", http.StatusBadRequest)
+	result, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, "GetByID", err)
 		return
 	}
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1", table)
-	var result T
-	if err := DB.Get(&result, query, id); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Не найден!", http.StatusNotFound)
-			return
-		}
-		log.Println("GetByID DB error:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	// ETag — от сохранённой записи, как в If-Match; пересчёт в ?currency= его не меняет
+	etag := computeETag(result)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(convertOne(r.Context(), result))
 }
 
-func GetMyData[T Helper](w http.ResponseWriter, r *http.Request) {
+func (h *Handler[T]) GetMyData(w http.ResponseWriter, r *http.Request) {
 	userID, err := store.GetIDUser(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 	var item T
-	table := item.GetNameTable()
-	if !isAllowedTable(table) {
-		http.Error(w, "Invalid resource", http.StatusBadRequest)
-		return
-	}
-	query := fmt.Sprintf("SELECT * FROM %s WHERE owner_id = $1", table)
-	result := []T{}
-	if err := DB.Select(&result, query, userID); err != nil {
-		log.Println("GetMyData DB error:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	lq, err := parseListQuery(item, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(result)
+	// owner_id всегда берём из токена, а не из параметров запроса
+	writeList(w, r, lq, func(ctx context.Context, q *ListQuery) ([]T, int, error) {
+		return h.repo.ListByOwner(ctx, userID, q)
+	})
 }
@@ -0,0 +1,106 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// SoftDeletable реализуют типы с колонкой deleted_at: Delete для них только
+// помечает запись, а обычные выборки её не видят.
+type SoftDeletable interface {
+	GetDeletedAt() *time.Time
+}
+
+// notDeletedCondition — условие «запись не в корзине» или пустая строка для обычных типов.
+func notDeletedCondition(item interface{}) string {
+	if _, ok := item.(SoftDeletable); ok {
+		return "deleted_at IS NULL"
+	}
+	return ""
+}
+
+// andNotDeleted дописывает условие «не в корзине» к запросу, уже содержащему WHERE.
+func andNotDeleted(item interface{}) string {
+	if cond := notDeletedCondition(item); cond != "" {
+		return " AND " + cond
+	}
+	return ""
+}
+
+// ReadTrash — список удалённых записей для админа, новые удаления сверху.
+func (h *Handler[T]) ReadTrash(w http.ResponseWriter, r *http.Request) {
+	var item T
+	if _, ok := interface{}(item).(SoftDeletable); !ok {
+		http.Error(w, "Invalid resource", http.StatusBadRequest)
+		return
+	}
+	lq, err := parseScopedListQuery(item, r.URL.Query(), true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("sort") == "" {
+		lq.SortColumn, lq.SortDesc = "deleted_at", true
+	}
+	writeList(w, r, lq, h.repo.List)
+}
+
+// Restore возвращает запись из корзины.
+func (h *Handler[T]) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.repo.Restore(requestContext(r), id); err != nil {
+		writeRepoError(w, "Restore", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Запись восстановлена"})
+}
+
+// TrashRetention читает срок хранения корзины из TRASH_RETENTION_DAYS (по умолчанию 30 дней).
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return defaultTrashRetention
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PurgeTrash окончательно удаляет записи, пролежавшие в корзине дольше retention.
+// Корзины чистятся в переданном порядке — зависимые таблицы должны идти первыми.
+func PurgeTrash(ctx context.Context, retention time.Duration, purgers ...Purger) (int64, error) {
+	var total int64
+	before := time.Now().Add(-retention)
+	for _, p := range purgers {
+		n, err := p.Purge(ctx, before)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// RunTrashPurger периодически чистит корзину; запускается в отдельной горутине.
+func RunTrashPurger(interval, retention time.Duration, purgers ...Purger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := PurgeTrash(context.Background(), retention, purgers...)
+		if err != nil {
+			log.Println("Trash purge error:", err)
+		} else if n > 0 {
+			log.Printf("Trash purge: удалено %d записей", n)
+		}
+		<-ticker.C
+	}
+}
@@ -2,6 +2,8 @@ package estate
 
 import (
 	"example-app/pkg/store"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -10,33 +12,46 @@ type Helper interface {
 	GetNameColumns() string
 	GetPlaceholder() string
 	GetValues() []interface{}
+	GetFilters() map[string]Filter
+	GetSortColumns() []string
 }
 type Property struct {
-	ID        int       `json:"id" db:"id"`
-	Address   string    `json:"address" db:"address"`
-	Type      string    `json:"type" db:"type"`
-	Price     float64   `json:"price" db:"price"`
-	OwnerID   int       `json:"owner_id" db:"owner_id"`
-	Status    string    `json:"status" db:"status"`
-	CreatedAt time.Time `json:"-" db:"created_at"`
-	UpdatedAt time.Time `json:"-" db:"updated_at"`
+	ID        int        `json:"id" db:"id"`
+	Address   string     `json:"address" db:"address"`
+	Type      string     `json:"type" db:"type"`
+	Price     Amount     `json:"price" db:"price"`
+	Currency  Currency   `json:"currency" db:"currency"`
+	OwnerID   int        `json:"owner_id" db:"owner_id"`
+	Status    string     `json:"status" db:"status"` // меняется только переходами, см. lifecycle.go
+	Latitude  *float64   `json:"latitude" db:"latitude"`
+	Longitude *float64   `json:"longitude" db:"longitude"`
+	CreatedAt time.Time  `json:"-" db:"created_at"`
+	UpdatedAt time.Time  `json:"-" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Converted *Converted `json:"converted,omitempty" db:"-"`
 }
 type Purchase struct {
-	ID           int       `json:"id" db:"id"`
-	PropertyID   int       `json:"property_id" db:"property_id"`
-	SellerID     int       `json:"seller_id" db:"seller_id"`
-	PurchaseDate time.Time `json:"purchase_date" db:"purchase_date"`
-	InitialPrice float64   `json:"initial_price" db:"initial_price"`
-	OwnerID      int       `json:"owner_id" db:"owner_id"`
+	ID           int        `json:"id" db:"id"`
+	PropertyID   int        `json:"property_id" db:"property_id"`
+	SellerID     int        `json:"seller_id" db:"seller_id"`
+	PurchaseDate time.Time  `json:"purchase_date" db:"purchase_date"`
+	InitialPrice Amount     `json:"initial_price" db:"initial_price"`
+	Currency     Currency   `json:"currency" db:"currency"`
+	OwnerID      int        `json:"owner_id" db:"owner_id"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Converted    *Converted `json:"converted,omitempty" db:"-"`
 }
 
 type Sale struct {
-	ID         int       `json:"id" db:"id"`
-	PropertyID int       `json:"property_id" db:"property_id"`
-	BuyerID    int       `json:"buyer_id" db:"buyer_id"`
-	SaleDate   time.Time `json:"sale_date" db:"sale_date"`
-	FinalPrice float64   `json:"final_price" db:"final_price"`
-	OwnerID    int       `json:"owner_id" db:"owner_id"`
+	ID         int        `json:"id" db:"id"`
+	PropertyID int        `json:"property_id" db:"property_id"`
+	BuyerID    int        `json:"buyer_id" db:"buyer_id"`
+	SaleDate   time.Time  `json:"sale_date" db:"sale_date"`
+	FinalPrice Amount     `json:"final_price" db:"final_price"`
+	Currency   Currency   `json:"currency" db:"currency"`
+	OwnerID    int        `json:"owner_id" db:"owner_id"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Converted  *Converted `json:"converted,omitempty" db:"-"`
 }
 type User struct {
 	store.User
@@ -46,43 +61,160 @@ func (p Property) GetNameTable() string {
 	return "properties"
 }
 func (p Property) GetNameColumns() string {
-	return "address, type, price, status"
+	return "address, type, price, currency, latitude, longitude"
 }
 func (p Property) GetPlaceholder() string {
-	return "$1, $2, $3, $4"
+	return "$1, $2, $3, $4, $5, $6"
 }
 func (p Property) GetValues() []interface{} {
 	return []interface{}{
-		p.Address, p.Type, p.Price, p.Status,
+		p.Address, p.Type, p.Price, p.Currency, p.Latitude, p.Longitude,
+	}
+}
+func (p Property) GetGeoColumns() (string, string) {
+	return "latitude", "longitude"
+}
+func (p Property) GetUpdatedAt() time.Time {
+	return p.UpdatedAt
+}
+func (p Property) GetDeletedAt() *time.Time {
+	return p.DeletedAt
+}
+func (p Property) withConverted(c *Converter) Property {
+	p.Converted = convertFields(c, p.Currency, map[string]Amount{"price": p.Price})
+	return p
+}
+func (p Property) withDefaults() Property {
+	if p.Status == "" {
+		p.Status = StatusDraft
 	}
+	return p
+}
+func (p Property) Validate() error {
+	if strings.TrimSpace(p.Address) == "" {
+		return fmt.Errorf("address is required")
+	}
+	if p.Price < 0 {
+		return fmt.Errorf("price must not be negative")
+	}
+	if err := p.Currency.Validate(); err != nil {
+		return err
+	}
+	if (p.Latitude == nil) != (p.Longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be set together")
+	}
+	if p.Latitude != nil && (*p.Latitude < -90 || *p.Latitude > 90) {
+		return fmt.Errorf("latitude out of range")
+	}
+	if p.Longitude != nil && (*p.Longitude < -180 || *p.Longitude > 180) {
+		return fmt.Errorf("longitude out of range")
+	}
+	return nil
+}
+func (p Property) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"price_min":    {Column: "price", Op: ">=", Kind: KindDecimal},
+		"price_max":    {Column: "price", Op: "<=", Kind: KindDecimal},
+		"type":         {Column: "type", Op: "=", Kind: KindString},
+		"status":       {Column: "status", Op: "=", Kind: KindString},
+		"owner_id":     {Column: "owner_id", Op: "=", Kind: KindInt},
+		"created_from": {Column: "created_at", Op: ">=", Kind: KindTime},
+		"created_to":   {Column: "created_at", Op: "<=", Kind: KindTime},
+	}
+}
+func (p Property) GetSortColumns() []string {
+	return []string{"id", "address", "type", "price", "status", "created_at", "updated_at"}
 }
 func (p Purchase) GetNameTable() string {
 	return "purchases"
 }
 func (p Purchase) GetNameColumns() string {
-	return "property_id, seller_id, initial_price"
+	return "property_id, seller_id, initial_price, currency"
 }
 func (p Purchase) GetPlaceholder() string {
-	return "$1, $2, $3"
+	return "$1, $2, $3, $4"
 }
 func (p Purchase) GetValues() []interface{} {
 	return []interface{}{
-		p.PropertyID, p.SellerID, p.InitialPrice,
+		p.PropertyID, p.SellerID, p.InitialPrice, p.Currency,
 	}
 }
+func (p Purchase) Validate() error {
+	if p.InitialPrice < 0 {
+		return fmt.Errorf("initial_price must not be negative")
+	}
+	return p.Currency.Validate()
+}
+func (p Purchase) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"price_min":   {Column: "initial_price", Op: ">=", Kind: KindDecimal},
+		"price_max":   {Column: "initial_price", Op: "<=", Kind: KindDecimal},
+		"property_id": {Column: "property_id", Op: "=", Kind: KindInt},
+		"seller_id":   {Column: "seller_id", Op: "=", Kind: KindInt},
+		"owner_id":    {Column: "owner_id", Op: "=", Kind: KindInt},
+		"date_from":   {Column: "purchase_date", Op: ">=", Kind: KindTime},
+		"date_to":     {Column: "purchase_date", Op: "<=", Kind: KindTime},
+	}
+}
+func (p Purchase) GetSortColumns() []string {
+	return []string{"id", "property_id", "initial_price", "purchase_date"}
+}
+func (p Purchase) GetDeletedAt() *time.Time {
+	return p.DeletedAt
+}
+func (p Purchase) withConverted(c *Converter) Purchase {
+	p.Converted = convertFields(c, p.Currency, map[string]Amount{"initial_price": p.InitialPrice})
+	return p
+}
 func (s Sale) GetNameTable() string {
 	return "sales"
 }
 func (s Sale) GetNameColumns() string {
-	return "property_id, buyer_id, final_price"
+	return "property_id, buyer_id, final_price, currency"
 }
 func (s Sale) GetPlaceholder() string {
-	return "$1, $2, $3"
+	return "$1, $2, $3, $4"
 }
 func (s Sale) GetValues() []interface{} {
 	return []interface{}{
-		s.PropertyID, s.BuyerID, s.FinalPrice,
+		s.PropertyID, s.BuyerID, s.FinalPrice, s.Currency,
+	}
+}
+func (s Sale) Validate() error {
+	if s.PropertyID <= 0 {
+		return fmt.Errorf("property_id is required")
+	}
+	if s.BuyerID <= 0 {
+		return fmt.Errorf("buyer_id is required")
+	}
+	if s.FinalPrice < 0 {
+		return fmt.Errorf("final_price must not be negative")
 	}
+	if err := s.Currency.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+func (s Sale) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"price_min":   {Column: "final_price", Op: ">=", Kind: KindDecimal},
+		"price_max":   {Column: "final_price", Op: "<=", Kind: KindDecimal},
+		"property_id": {Column: "property_id", Op: "=", Kind: KindInt},
+		"buyer_id":    {Column: "buyer_id", Op: "=", Kind: KindInt},
+		"owner_id":    {Column: "owner_id", Op: "=", Kind: KindInt},
+		"date_from":   {Column: "sale_date", Op: ">=", Kind: KindTime},
+		"date_to":     {Column: "sale_date", Op: "<=", Kind: KindTime},
+	}
+}
+func (s Sale) GetSortColumns() []string {
+	return []string{"id", "property_id", "final_price", "sale_date"}
+}
+func (s Sale) GetDeletedAt() *time.Time {
+	return s.DeletedAt
+}
+func (s Sale) withConverted(c *Converter) Sale {
+	s.Converted = convertFields(c, s.Currency, map[string]Amount{"final_price": s.FinalPrice})
+	return s
 }
 func (u User) GetNameTable() string {
 	return "users"
@@ -98,3 +230,17 @@ func (u User) GetValues() []interface{} {
 		u.RoleID,
 	}
 }
+func (u User) GetUpdatedAt() time.Time {
+	return u.UpdatedAt
+}
+func (u User) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"role_id":      {Column: "role_id", Op: "=", Kind: KindInt},
+		"email":        {Column: "email", Op: "=", Kind: KindString},
+		"created_from": {Column: "created_at", Op: ">=", Kind: KindTime},
+		"created_to":   {Column: "created_at", Op: "<=", Kind: KindTime},
+	}
+}
+func (u User) GetSortColumns() []string {
+	return []string{"id", "username", "email", "role_id", "created_at"}
+}
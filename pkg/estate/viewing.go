@@ -0,0 +1,363 @@
+package estate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example-app/pkg/store"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Статусы показа. Пересекаться по времени не могут только requested и confirmed.
+const (
+	ViewingRequested = "requested"
+	ViewingConfirmed = "confirmed"
+	ViewingDeclined  = "declined"
+	ViewingCancelled = "cancelled"
+)
+
+// Viewing — показ объекта клиенту. owner_id — агент объекта на момент записи:
+// он подтверждает или отклоняет показ и видит его в своём календаре.
+type Viewing struct {
+	ID         int       `json:"id" db:"id"`
+	PropertyID int       `json:"property_id" db:"property_id"`
+	ClientID   int       `json:"client_id" db:"client_id"`
+	StartsAt   time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt     time.Time `json:"ends_at" db:"ends_at"`
+	Note       string    `json:"note" db:"note"`
+	Status     string    `json:"status" db:"status"`
+	OwnerID    int       `json:"owner_id" db:"owner_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"-" db:"updated_at"`
+}
+
+func (v Viewing) GetNameTable() string {
+	return "viewings"
+}
+func (v Viewing) GetNameColumns() string {
+	return "property_id, client_id, starts_at, ends_at, note"
+}
+func (v Viewing) GetPlaceholder() string {
+	return "$1, $2, $3, $4, $5"
+}
+func (v Viewing) GetValues() []interface{} {
+	return []interface{}{
+		v.PropertyID, v.ClientID, v.StartsAt, v.EndsAt, v.Note,
+	}
+}
+func (v Viewing) GetUpdatedAt() time.Time {
+	return v.UpdatedAt
+}
+func (v Viewing) Validate() error {
+	if v.PropertyID <= 0 {
+		return fmt.Errorf("property_id is required")
+	}
+	if !v.StartsAt.After(time.Now()) {
+		return fmt.Errorf("starts_at must be in the future")
+	}
+	if !v.EndsAt.After(v.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+func (v Viewing) GetFilters() map[string]Filter {
+	return map[string]Filter{
+		"property_id": {Column: "property_id", Op: "=", Kind: KindInt},
+		"client_id":   {Column: "client_id", Op: "=", Kind: KindInt},
+		"status":      {Column: "status", Op: "=", Kind: KindString},
+		"owner_id":    {Column: "owner_id", Op: "=", Kind: KindInt},
+		"from":        {Column: "starts_at", Op: ">=", Kind: KindTime},
+		"to":          {Column: "starts_at", Op: "<=", Kind: KindTime},
+	}
+}
+func (v Viewing) GetSortColumns() []string {
+	return []string{"id", "starts_at", "created_at"}
+}
+func (v Viewing) withDefaults() Viewing {
+	if v.Status == "" {
+		v.Status = ViewingRequested
+	}
+	return v
+}
+
+// activeViewing — условия «показ ещё в силе» (requested или confirmed).
+var activeViewing = []Condition{
+	{Column: "status", Op: "!=", Value: ViewingDeclined},
+	{Column: "status", Op: "!=", Value: ViewingCancelled},
+}
+
+// ViewingService записывает на показы и ведёт календарь агентов.
+type ViewingService struct {
+	tx         Transactor
+	properties Repository[Property]
+	viewings   Repository[Viewing]
+}
+
+func NewViewingService(repos *Repositories) *ViewingService {
+	return &ViewingService{tx: repos.Tx, properties: repos.Properties, viewings: repos.Viewings}
+}
+
+// Request записывает clientID на показ объекта. Показ достаётся агенту объекта;
+// ErrConflict — объект не выставлен или время занято у объекта либо у агента.
+func (s *ViewingService) Request(ctx context.Context, v Viewing, clientID int) (Viewing, error) {
+	var saved Viewing
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		p, err := s.properties.Get(ctx, v.PropertyID)
+		if errors.Is(err, ErrNotFound) {
+			return newStatusError(http.StatusUnprocessableEntity, "property %d not found", v.PropertyID)
+		}
+		if err != nil {
+			return err
+		}
+		if p.Status != StatusAvailable && p.Status != StatusReserved {
+			return fmt.Errorf("%w: property %d is %s", ErrConflict, p.ID, p.Status)
+		}
+		for _, c := range []Condition{
+			{Column: "property_id", Op: "=", Value: p.ID},
+			{Column: "owner_id", Op: "=", Value: p.OwnerID},
+		} {
+			busy, err := s.overlaps(ctx, v, c)
+			if err != nil {
+				return err
+			}
+			if busy {
+				return fmt.Errorf("%w: time slot is already booked", ErrConflict)
+			}
+		}
+		v.ClientID = clientID
+		rows, err := s.viewings.Insert(ctx, p.OwnerID, v)
+		if err != nil {
+			return err
+		}
+		saved = rows[0]
+		return nil
+	})
+	return saved, err
+}
+
+// overlaps — есть ли действующий показ, пересекающийся с v, среди выбранных by.
+func (s *ViewingService) overlaps(ctx context.Context, v Viewing, by Condition) (bool, error) {
+	conds := append([]Condition{
+		by,
+		{Column: "starts_at", Op: "<", Value: v.EndsAt},
+		{Column: "ends_at", Op: ">", Value: v.StartsAt},
+	}, activeViewing...)
+	rows, _, err := s.viewings.List(ctx, &ListQuery{Conditions: conds, Limit: 1, NoTotal: true})
+	return len(rows) > 0, err
+}
+
+// setStatus переводит показ из одного из from в status; check дополняет проверку.
+func (s *ViewingService) setStatus(ctx context.Context, id int, status string, from []string, check func(current Viewing) error) (Viewing, error) {
+	return s.viewings.SetColumn(ctx, id, "status", status, func(current Viewing) error {
+		if !containsString(from, current.Status) {
+			return fmt.Errorf("%w: viewing %d is %s", ErrConflict, id, current.Status)
+		}
+		if check != nil {
+			return check(current)
+		}
+		return nil
+	})
+}
+
+func (s *ViewingService) Confirm(ctx context.Context, id int) (Viewing, error) {
+	return s.setStatus(ctx, id, ViewingConfirmed, []string{ViewingRequested}, nil)
+}
+
+func (s *ViewingService) Decline(ctx context.Context, id int) (Viewing, error) {
+	return s.setStatus(ctx, id, ViewingDeclined, []string{ViewingRequested, ViewingConfirmed}, nil)
+}
+
+// Cancel — отмена показа клиентом или агентом; остальным 403.
+func (s *ViewingService) Cancel(ctx context.Context, id, userID int) (Viewing, error) {
+	return s.setStatus(ctx, id, ViewingCancelled, []string{ViewingRequested, ViewingConfirmed}, func(current Viewing) error {
+		if current.ClientID != userID && current.OwnerID != userID {
+			return newStatusError(http.StatusForbidden, "Недостаточно прав доступа")
+		}
+		return nil
+	})
+}
+
+// Create — POST /viewings: клиент из токена запрашивает время показа.
+func (s *ViewingService) Create(w http.ResponseWriter, r *http.Request) {
+	var v Viewing
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := v.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	saved, err := s.Request(requestContext(r), v, userID)
+	if err != nil {
+		writeRepoError(w, "RequestViewing", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// statusHandler — POST /viewings/{id}/<действие> поверх одного из методов сервиса.
+func (s *ViewingService) statusHandler(op string, fn func(ctx context.Context, id, userID int) (Viewing, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := urlID(w, r)
+		if !ok {
+			return
+		}
+		userID, err := store.GetIDUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		v, err := fn(requestContext(r), id, userID)
+		if err != nil {
+			writeRepoError(w, op, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+// ConfirmHandler и DeclineHandler вешаются за RequireAdminOrAgent: он пускает
+// только агента показа (owner_id) и админа.
+func (s *ViewingService) ConfirmHandler() http.HandlerFunc {
+	return s.statusHandler("ConfirmViewing", func(ctx context.Context, id, _ int) (Viewing, error) {
+		return s.Confirm(ctx, id)
+	})
+}
+
+func (s *ViewingService) DeclineHandler() http.HandlerFunc {
+	return s.statusHandler("DeclineViewing", func(ctx context.Context, id, _ int) (Viewing, error) {
+		return s.Decline(ctx, id)
+	})
+}
+
+func (s *ViewingService) CancelHandler() http.HandlerFunc {
+	return s.statusHandler("CancelViewing", s.Cancel)
+}
+
+// upcoming — выборка действующих показов, которые ещё не закончились.
+func upcoming(lq *ListQuery, by Condition) *ListQuery {
+	lq.Conditions = append(append(lq.Conditions, by, Condition{Column: "ends_at", Op: ">=", Value: time.Now()}), activeViewing...)
+	return lq
+}
+
+// Upcoming — GET /viewings/upcoming: предстоящие показы клиента из токена,
+// с теми же фильтрами и пагинацией, что и GetMyData.
+func (s *ViewingService) Upcoming(w http.ResponseWriter, r *http.Request) {
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	lq, err := parseListQuery(Viewing{}, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("sort") == "" {
+		lq.SortColumn = "starts_at"
+	}
+	writeList(w, r, upcoming(lq, Condition{Column: "client_id", Op: "=", Value: userID}), s.viewings.List)
+}
+
+// Calendar — GET /viewings/calendar.ics: предстоящие показы агента в формате iCalendar.
+func (s *ViewingService) Calendar(w http.ResponseWriter, r *http.Request) {
+	userID, err := store.GetIDUser(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	lq := upcoming(&ListQuery{SortColumn: "starts_at", NoTotal: true}, Condition{Column: "owner_id", Op: "=", Value: userID})
+	viewings, _, err := s.viewings.List(r.Context(), lq)
+	if err != nil {
+		writeRepoError(w, "Calendar", err)
+		return
+	}
+	addresses := map[int]string{}
+	for _, v := range viewings {
+		if _, ok := addresses[v.PropertyID]; ok {
+			continue
+		}
+		p, err := s.properties.Get(r.Context(), v.PropertyID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			writeRepoError(w, "Calendar", err)
+			return
+		}
+		addresses[v.PropertyID] = p.Address
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="viewings.ics"`)
+	w.Write([]byte(viewingsICS(viewings, addresses, time.Now())))
+}
+
+// viewingsICS собирает VCALENDAR (RFC 5545); неподтверждённые показы — TENTATIVE.
+func viewingsICS(viewings []Viewing, addresses map[int]string, now time.Time) string {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(icsFold(s))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//RealEstateAgency//Viewings//RU")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	for _, v := range viewings {
+		status := "TENTATIVE"
+		if v.Status == ViewingConfirmed {
+			status = "CONFIRMED"
+		}
+		addr := addresses[v.PropertyID]
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:viewing-%d@realestateagency", v.ID))
+		line("DTSTAMP:" + icsTime(now))
+		line("DTSTART:" + icsTime(v.StartsAt))
+		line("DTEND:" + icsTime(v.EndsAt))
+		line("SUMMARY:" + icsEscape(fmt.Sprintf("Показ: %s", addr)))
+		line("LOCATION:" + icsEscape(addr))
+		if v.Note != "" {
+			line("DESCRIPTION:" + icsEscape(v.Note))
+		}
+		line("STATUS:" + status)
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// icsFold переносит строки длиннее 75 октетов, не разрывая символы UTF-8.
+func icsFold(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
@@ -0,0 +1,218 @@
+// Package migrate применяет SQL-миграции, встроенные в бинарник.
+// Файлы лежат в migrations/ и называются NNNN_описание.up.sql / NNNN_описание.down.sql;
+// применённые версии хранятся в таблице schema_migrations.
+package migrate
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// lockKey — ключ advisory lock, чтобы два экземпляра сервера не мигрировали одновременно.
+const lockKey = 724819
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status — версия миграции и время применения (nil, если не применена).
+type Status struct {
+	Version   int64      `db:"version"`
+	Name      string     `db:"-"`
+	AppliedAt *time.Time `db:"applied_at"`
+}
+
+// Load читает встроенные миграции, отсортированные по версии.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, title, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad migration file name: %s", name)
+		}
+		body, err := files.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	var out []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Up применяет все ещё не применённые миграции, каждую в своей транзакции.
+func Up(db *sqlx.DB) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	applied := 0
+	err = withLock(db, func(conn *sqlx.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if done[m.Version] {
+				continue
+			}
+			if err := run(conn, m.Up, "INSERT INTO schema_migrations (version) VALUES ($1)", m.Version); err != nil {
+				return fmt.Errorf("migration %04d_%s: %v", m.Version, m.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down откатывает steps последних применённых миграций.
+func Down(db *sqlx.DB, steps int) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	reverted := 0
+	err = withLock(db, func(conn *sqlx.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+			m := migrations[i]
+			if !done[m.Version] {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+			}
+			if err := run(conn, m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version); err != nil {
+				return fmt.Errorf("rollback %04d_%s: %v", m.Version, m.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// List возвращает все известные миграции с отметкой о применении.
+func List(db *sqlx.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createTable); err != nil {
+		return nil, err
+	}
+	var rows []Status
+	if err := db.Select(&rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+	appliedAt := map[int64]*time.Time{}
+	for _, r := range rows {
+		appliedAt[r.Version] = r.AppliedAt
+	}
+	out := make([]Status, len(migrations))
+	for i, m := range migrations {
+		out[i] = Status{Version: m.Version, Name: m.Name, AppliedAt: appliedAt[m.Version]}
+	}
+	return out, nil
+}
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+func withLock(db *sqlx.DB, fn func(conn *sqlx.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func appliedVersions(conn *sqlx.Conn) (map[int64]bool, error) {
+	var versions []int64
+	if err := conn.SelectContext(context.Background(), &versions, "SELECT version FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+	done := map[int64]bool{}
+	for _, v := range versions {
+		done[v] = true
+	}
+	return done, nil
+}
+
+// run выполняет скрипт миграции и отметку в schema_migrations одной транзакцией.
+func run(conn *sqlx.Conn, script, mark string, version int64) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, mark, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,5 @@
+DROP TABLE sales;
+DROP TABLE purchases;
+DROP TABLE properties;
+DROP TABLE users;
+DROP TABLE roles;
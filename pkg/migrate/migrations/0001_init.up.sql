@@ -0,0 +1,54 @@
+-- Базовая схема. IF NOT EXISTS — для баз, созданных до появления миграций вручную
+-- тем же скриптом: на них 0001 проходит без ошибок и только отмечается применённой.
+CREATE TABLE IF NOT EXISTS roles (
+    id   INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+INSERT INTO roles (id, name) VALUES (1, 'admin'), (2, 'agent'), (3, 'user') ON CONFLICT (id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS users (
+    id            SERIAL PRIMARY KEY,
+    username      TEXT NOT NULL,
+    email         TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role_id       INTEGER NOT NULL DEFAULT 3 REFERENCES roles (id),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS properties (
+    id         SERIAL PRIMARY KEY,
+    address    TEXT NOT NULL,
+    type       TEXT NOT NULL DEFAULT '',
+    price      NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    owner_id   INTEGER NOT NULL REFERENCES users (id),
+    status     TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS properties_owner_id_idx ON properties (owner_id);
+CREATE INDEX IF NOT EXISTS properties_status_idx ON properties (status);
+
+CREATE TABLE IF NOT EXISTS purchases (
+    id            SERIAL PRIMARY KEY,
+    property_id   INTEGER NOT NULL REFERENCES properties (id),
+    seller_id     INTEGER NOT NULL REFERENCES users (id),
+    purchase_date TIMESTAMPTZ NOT NULL DEFAULT now(),
+    initial_price NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    owner_id      INTEGER NOT NULL REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS purchases_owner_id_idx ON purchases (owner_id);
+
+CREATE TABLE IF NOT EXISTS sales (
+    id          SERIAL PRIMARY KEY,
+    property_id INTEGER NOT NULL REFERENCES properties (id),
+    buyer_id    INTEGER NOT NULL REFERENCES users (id),
+    sale_date   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    final_price NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    owner_id    INTEGER NOT NULL REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS sales_owner_id_idx ON sales (owner_id);
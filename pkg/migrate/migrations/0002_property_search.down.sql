@@ -0,0 +1,2 @@
+DROP INDEX properties_address_trgm_idx;
+DROP INDEX properties_search_idx;
@@ -0,0 +1,8 @@
+-- CREATE EXTENSION требует прав суперпользователя (или владельца базы для
+-- доверенных расширений в PostgreSQL 13+); иначе расширение создаётся заранее, см. README.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- выражение должно совпадать с searchDocument в pkg/estate/search.go
+CREATE INDEX properties_search_idx ON properties
+    USING GIN (to_tsvector('simple', coalesce(address, '') || ' ' || coalesce(type, '')));
+CREATE INDEX properties_address_trgm_idx ON properties USING GIN (address gin_trgm_ops);
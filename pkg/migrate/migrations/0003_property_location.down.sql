@@ -0,0 +1,2 @@
+DROP INDEX properties_location_idx;
+ALTER TABLE properties DROP COLUMN longitude, DROP COLUMN latitude;
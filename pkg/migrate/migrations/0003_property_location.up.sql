@@ -0,0 +1,5 @@
+ALTER TABLE properties
+    ADD COLUMN latitude  DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180);
+
+CREATE INDEX properties_location_idx ON properties (latitude, longitude);
@@ -0,0 +1,3 @@
+ALTER TABLE sales DROP COLUMN deleted_at;
+ALTER TABLE purchases DROP COLUMN deleted_at;
+ALTER TABLE properties DROP COLUMN deleted_at;
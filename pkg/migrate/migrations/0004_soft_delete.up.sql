@@ -0,0 +1,7 @@
+ALTER TABLE properties ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE purchases ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE sales ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX properties_deleted_at_idx ON properties (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX purchases_deleted_at_idx ON purchases (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX sales_deleted_at_idx ON sales (deleted_at) WHERE deleted_at IS NOT NULL;
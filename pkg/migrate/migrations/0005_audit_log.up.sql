@@ -0,0 +1,14 @@
+CREATE TABLE audit_log (
+    id         BIGSERIAL PRIMARY KEY,
+    actor_id   INTEGER,
+    action     TEXT NOT NULL,
+    table_name TEXT NOT NULL,
+    record_id  INTEGER NOT NULL,
+    before     JSONB,
+    after      JSONB,
+    ip         TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_log_record_idx ON audit_log (table_name, record_id, created_at);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id, created_at);
@@ -0,0 +1,4 @@
+DROP TABLE property_transitions;
+
+ALTER TABLE properties DROP CONSTRAINT properties_status_check;
+ALTER TABLE properties ALTER COLUMN status SET DEFAULT '';
@@ -0,0 +1,21 @@
+UPDATE properties SET status = 'available' WHERE status = 'active';
+UPDATE properties SET status = 'draft'
+WHERE status NOT IN ('draft', 'available', 'reserved', 'under_contract', 'sold', 'withdrawn');
+
+ALTER TABLE properties ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE properties ADD CONSTRAINT properties_status_check
+    CHECK (status IN ('draft', 'available', 'reserved', 'under_contract', 'sold', 'withdrawn'));
+
+CREATE TABLE property_transitions (
+    id          SERIAL PRIMARY KEY,
+    property_id INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    event       TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status   TEXT NOT NULL,
+    actor_id    INTEGER,
+    ip          TEXT NOT NULL DEFAULT '',
+    owner_id    INTEGER NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX property_transitions_property_idx ON property_transitions (property_id, created_at);
@@ -0,0 +1,17 @@
+CREATE TABLE reservations (
+    id          SERIAL PRIMARY KEY,
+    property_id INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    client_id   INTEGER NOT NULL REFERENCES users (id),
+    deposit     NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    status      TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'released', 'expired')),
+    owner_id    INTEGER NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- не больше одной действующей брони на объект
+CREATE UNIQUE INDEX reservations_active_property_idx ON reservations (property_id) WHERE status = 'active';
+CREATE INDEX reservations_expires_at_idx ON reservations (expires_at) WHERE status = 'active';
+CREATE INDEX reservations_owner_id_idx ON reservations (owner_id);
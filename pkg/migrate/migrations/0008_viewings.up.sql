@@ -0,0 +1,25 @@
+-- как и pg_trgm в 0002: нужны права на CREATE EXTENSION или заранее созданное расширение
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE viewings (
+    id          SERIAL PRIMARY KEY,
+    property_id INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    client_id   INTEGER NOT NULL REFERENCES users (id),
+    starts_at   TIMESTAMPTZ NOT NULL,
+    ends_at     TIMESTAMPTZ NOT NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL DEFAULT 'requested'
+        CHECK (status IN ('requested', 'confirmed', 'declined', 'cancelled')),
+    owner_id    INTEGER NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (ends_at > starts_at),
+    -- показы одного объекта и одного агента не пересекаются
+    EXCLUDE USING gist (property_id WITH =, tstzrange(starts_at, ends_at) WITH &&)
+        WHERE (status IN ('requested', 'confirmed')),
+    EXCLUDE USING gist (owner_id WITH =, tstzrange(starts_at, ends_at) WITH &&)
+        WHERE (status IN ('requested', 'confirmed'))
+);
+
+CREATE INDEX viewings_client_id_idx ON viewings (client_id, starts_at);
+CREATE INDEX viewings_owner_id_idx ON viewings (owner_id, starts_at);
@@ -0,0 +1,21 @@
+CREATE TABLE offers (
+    id          SERIAL PRIMARY KEY,
+    property_id INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    buyer_id    INTEGER NOT NULL REFERENCES users (id),
+    parent_id   INTEGER REFERENCES offers (id) ON DELETE CASCADE,
+    amount      NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+    message     TEXT NOT NULL DEFAULT '',
+    made_by     TEXT NOT NULL CHECK (made_by IN ('buyer', 'seller')),
+    status      TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'rejected', 'countered', 'withdrawn', 'expired')),
+    expires_at  TIMESTAMPTZ NOT NULL,
+    owner_id    INTEGER NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- переписка покупателя по объекту: в каждый момент ждёт ответа не больше одного предложения
+CREATE INDEX offers_thread_idx ON offers (property_id, buyer_id, id);
+CREATE UNIQUE INDEX offers_pending_thread_idx ON offers (property_id, buyer_id) WHERE status = 'pending';
+CREATE INDEX offers_expires_at_idx ON offers (expires_at) WHERE status = 'pending';
+CREATE INDEX offers_owner_id_idx ON offers (owner_id);
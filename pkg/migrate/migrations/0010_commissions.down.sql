@@ -0,0 +1,2 @@
+DROP TABLE commissions;
+DROP TABLE commission_plans;
@@ -0,0 +1,33 @@
+CREATE TABLE commission_plans (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    kind       TEXT NOT NULL CHECK (kind IN ('flat', 'tiered')),
+    rate       NUMERIC(6, 3) NOT NULL DEFAULT 0,
+    tiers      JSONB NOT NULL DEFAULT '[]',
+    -- NULL — план по умолчанию для агентов без своего плана
+    agent_id   INTEGER REFERENCES users (id),
+    owner_id   INTEGER NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX commission_plans_agent_idx ON commission_plans (agent_id) WHERE agent_id IS NOT NULL;
+CREATE UNIQUE INDEX commission_plans_default_idx ON commission_plans ((agent_id IS NULL)) WHERE agent_id IS NULL;
+
+CREATE TABLE commissions (
+    id         SERIAL PRIMARY KEY,
+    -- RESTRICT: выплаченные начисления не удаляются вместе с продажей из корзины
+    sale_id    INTEGER NOT NULL REFERENCES sales (id) ON DELETE RESTRICT,
+    plan_id    INTEGER REFERENCES commission_plans (id) ON DELETE SET NULL,
+    sale_price NUMERIC(14, 2) NOT NULL,
+    share      NUMERIC(5, 2) NOT NULL,
+    amount     NUMERIC(14, 2) NOT NULL,
+    status     TEXT NOT NULL DEFAULT 'accrued' CHECK (status IN ('accrued', 'paid')),
+    paid_at    TIMESTAMPTZ,
+    -- агент, которому начислена комиссия
+    owner_id   INTEGER NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX commissions_owner_id_idx ON commissions (owner_id, status);
+CREATE INDEX commissions_sale_id_idx ON commissions (sale_id);
@@ -0,0 +1,2 @@
+DROP TABLE sale_documents;
+DROP TABLE document_templates;
@@ -0,0 +1,28 @@
+-- Шаблоны договора и расписки; без строки для вида действует встроенный шаблон.
+CREATE TABLE document_templates (
+    id         SERIAL PRIMARY KEY,
+    kind       TEXT NOT NULL UNIQUE CHECK (kind IN ('contract', 'receipt')),
+    title      TEXT NOT NULL,
+    body       TEXT NOT NULL,
+    owner_id   INTEGER NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Выданные документы: содержимое хранится как есть, checksum — sha256 от него.
+CREATE TABLE sale_documents (
+    id          SERIAL PRIMARY KEY,
+    -- RESTRICT: выданные документы переживают очистку корзины продаж
+    sale_id     INTEGER NOT NULL REFERENCES sales (id) ON DELETE RESTRICT,
+    kind        TEXT NOT NULL CHECK (kind IN ('contract', 'receipt')),
+    template_id INTEGER REFERENCES document_templates (id) ON DELETE SET NULL,
+    checksum    TEXT NOT NULL,
+    size        INTEGER NOT NULL,
+    content     BYTEA NOT NULL,
+    -- агент, оформивший продажу
+    owner_id    INTEGER NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (sale_id, kind)
+);
+
+CREATE INDEX sale_documents_checksum_idx ON sale_documents (checksum);
@@ -0,0 +1,8 @@
+DROP TABLE exchange_rates;
+
+ALTER TABLE commissions DROP COLUMN currency;
+ALTER TABLE offers DROP COLUMN currency;
+ALTER TABLE reservations DROP COLUMN currency;
+ALTER TABLE sales DROP COLUMN currency;
+ALTER TABLE purchases DROP COLUMN currency;
+ALTER TABLE properties DROP COLUMN currency;
@@ -0,0 +1,17 @@
+-- Валюта у каждой суммы; существующие суммы были в долларах.
+ALTER TABLE properties ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE purchases ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE sales ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE reservations ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE offers ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE commissions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+
+-- Курс — сколько единиц базовой валюты (USD) стоит одна единица currency.
+CREATE TABLE exchange_rates (
+    id         SERIAL PRIMARY KEY,
+    currency   CHAR(3) NOT NULL UNIQUE CHECK (currency ~ '^[A-Z]{3}$' AND currency <> 'USD'),
+    rate       NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+    owner_id   INTEGER NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+DROP TABLE property_photos;
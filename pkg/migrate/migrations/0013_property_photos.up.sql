@@ -0,0 +1,22 @@
+-- Фотографии объектов; сами файлы лежат в хранилище (STORAGE), здесь — их ключи.
+CREATE TABLE property_photos (
+    id           SERIAL PRIMARY KEY,
+    property_id  INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    position     INTEGER NOT NULL,
+    is_cover     BOOLEAN NOT NULL DEFAULT false,
+    content_type TEXT NOT NULL CHECK (content_type IN ('image/jpeg', 'image/png', 'image/gif')),
+    size         INTEGER NOT NULL,
+    width        INTEGER NOT NULL,
+    height       INTEGER NOT NULL,
+    original_key TEXT NOT NULL,
+    medium_key   TEXT NOT NULL,
+    thumb_key    TEXT NOT NULL,
+    -- кто загрузил
+    owner_id     INTEGER NOT NULL REFERENCES users (id),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX property_photos_property_idx ON property_photos (property_id, position);
+-- обложка у объекта одна
+CREATE UNIQUE INDEX property_photos_cover_idx ON property_photos (property_id) WHERE is_cover;
@@ -0,0 +1,2 @@
+DROP TABLE deal_document_versions;
+DROP TABLE deal_documents;
@@ -0,0 +1,33 @@
+-- Документы сделок: прикрепляются к объекту, покупке или продаже.
+-- Внешнего ключа на сделку нет — её наличие проверяет приложение.
+CREATE TABLE deal_documents (
+    id          SERIAL PRIMARY KEY,
+    entity_type TEXT NOT NULL CHECK (entity_type IN ('property', 'purchase', 'sale')),
+    entity_id   INTEGER NOT NULL,
+    category    TEXT NOT NULL CHECK (category IN ('contract', 'title_deed', 'id_document', 'power_of_attorney', 'other')),
+    title       TEXT NOT NULL,
+    -- номер последней версии
+    version     INTEGER NOT NULL DEFAULT 1,
+    owner_id    INTEGER NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX deal_documents_entity_idx ON deal_documents (entity_type, entity_id);
+
+-- Версии файлов; содержимое лежит в хранилище файлов (STORAGE) под storage_key.
+CREATE TABLE deal_document_versions (
+    id           SERIAL PRIMARY KEY,
+    document_id  INTEGER NOT NULL REFERENCES deal_documents (id) ON DELETE CASCADE,
+    version      INTEGER NOT NULL,
+    file_name    TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         INTEGER NOT NULL,
+    checksum     TEXT NOT NULL,
+    storage_key  TEXT NOT NULL,
+    owner_id     INTEGER NOT NULL REFERENCES users (id),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (document_id, version)
+);
+
+CREATE INDEX deal_document_versions_checksum_idx ON deal_document_versions (checksum);
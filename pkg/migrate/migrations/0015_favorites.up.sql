@@ -0,0 +1,12 @@
+-- Избранное пользователей; seen_* — цена и статус объекта на момент добавления.
+CREATE TABLE favorites (
+    id            SERIAL PRIMARY KEY,
+    property_id   INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    seen_price    NUMERIC(14, 2) NOT NULL,
+    seen_currency CHAR(3) NOT NULL DEFAULT 'USD',
+    seen_status   TEXT NOT NULL,
+    owner_id      INTEGER NOT NULL REFERENCES users (id),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (owner_id, property_id)
+);
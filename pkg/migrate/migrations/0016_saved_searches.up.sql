@@ -0,0 +1,27 @@
+-- Сохранённые поиски: query — параметры фильтра в формате GET /properties.
+CREATE TABLE saved_searches (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    query      TEXT NOT NULL DEFAULT '',
+    frequency  TEXT NOT NULL DEFAULT 'instant' CHECK (frequency IN ('instant', 'daily')),
+    owner_id   INTEGER NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Очередь уведомлений о совпадениях; owner_id — получатель.
+CREATE TABLE notifications (
+    id          SERIAL PRIMARY KEY,
+    search_id   INTEGER NOT NULL REFERENCES saved_searches (id) ON DELETE CASCADE,
+    property_id INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    reason      TEXT NOT NULL CHECK (reason IN ('new', 'price_changed')),
+    frequency   TEXT NOT NULL CHECK (frequency IN ('instant', 'daily')),
+    price       NUMERIC(14, 2) NOT NULL,
+    currency    CHAR(3) NOT NULL DEFAULT 'USD',
+    status      TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent')),
+    sent_at     TIMESTAMPTZ,
+    owner_id    INTEGER NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX notifications_pending_idx ON notifications (frequency, id) WHERE status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE commission_plans DROP COLUMN currency;
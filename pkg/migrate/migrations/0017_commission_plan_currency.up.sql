@@ -0,0 +1,2 @@
+-- Границы ступеней и суммы плана — в валюте плана; цена продажи пересчитывается в неё.
+ALTER TABLE commission_plans ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
@@ -0,0 +1 @@
+DROP TABLE listing_changes;
@@ -0,0 +1,10 @@
+-- Очередь изменений объектов для сохранённых поисков: пишется в транзакции
+-- публикации или смены цены, сверяется с поисками фоновой задачей.
+CREATE TABLE listing_changes (
+    id          SERIAL PRIMARY KEY,
+    property_id INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    reason      TEXT NOT NULL CHECK (reason IN ('new', 'price_changed')),
+    -- владелец объекта на момент изменения
+    owner_id    INTEGER NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
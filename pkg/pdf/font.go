@@ -0,0 +1,336 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	xfont "golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// ttf — встраиваемый TrueType-шрифт. Go Regular и Go Bold покрывают латиницу
+// и кириллицу, поэтому документ читается в любом просмотрщике, а не только в тех,
+// что подставляют свой шрифт вместо стандартной Helvetica.
+type ttf struct {
+	name string
+	data []byte
+	sf   *sfnt.Font
+	upem float64
+	// метрики для FontDescriptor, в тысячных долях кегля
+	ascent, descent, capHeight float64
+	bbox                       [4]float64
+}
+
+var fonts = [...]*ttf{
+	regular: loadTTF(goregular.TTF),
+	bold:    loadTTF(gobold.TTF),
+}
+
+func loadTTF(data []byte) *ttf {
+	sf, err := sfnt.Parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: embedded font: %v", err))
+	}
+	var b sfnt.Buffer
+	f := &ttf{data: data, sf: sf, upem: float64(sf.UnitsPerEm())}
+	if f.name, err = sf.Name(&b, sfnt.NameIDPostScript); err != nil {
+		panic(fmt.Sprintf("pdf: embedded font: %v", err))
+	}
+	ppem := fixed.I(int(sf.UnitsPerEm()))
+	m, err := sf.Metrics(&b, ppem, xfont.HintingNone)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: embedded font: %v", err))
+	}
+	bounds, err := sf.Bounds(&b, ppem, xfont.HintingNone)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: embedded font: %v", err))
+	}
+	// в sfnt ось y направлена вниз
+	f.ascent = f.scale(m.Ascent)
+	f.descent = -f.scale(m.Descent)
+	f.capHeight = f.scale(m.CapHeight)
+	f.bbox = [4]float64{f.scale(bounds.Min.X), -f.scale(bounds.Max.Y), f.scale(bounds.Max.X), -f.scale(bounds.Min.Y)}
+	return f
+}
+
+// scale переводит величину в единицах шрифта (при ppem = unitsPerEm) в тысячные доли кегля.
+func (f *ttf) scale(v fixed.Int26_6) float64 {
+	return float64(v) / 64 * 1000 / f.upem
+}
+
+// glyph — глиф символа; символы, которых в шрифте нет, выводятся как '?'.
+func (f *ttf) glyph(r rune) sfnt.GlyphIndex {
+	var b sfnt.Buffer
+	if g, err := f.sf.GlyphIndex(&b, r); err == nil && g != 0 {
+		return g
+	}
+	g, _ := f.sf.GlyphIndex(&b, '?')
+	return g
+}
+
+// advance — ширина глифа в тысячных долях кегля.
+func (f *ttf) advance(g sfnt.GlyphIndex) float64 {
+	var b sfnt.Buffer
+	adv, err := f.sf.GlyphAdvance(&b, g, fixed.I(int(f.sf.UnitsPerEm())), xfont.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return f.scale(adv)
+}
+
+// widths — массив /W для CID-шрифта: ширины использованных глифов.
+func (f *ttf) widths(used map[sfnt.GlyphIndex]rune) string {
+	var s strings.Builder
+	for _, g := range sortedGlyphs(used) {
+		fmt.Fprintf(&s, "%d [%.0f] ", g, f.advance(g))
+	}
+	return strings.TrimSpace(s.String())
+}
+
+// toUnicode — CMap для копирования и поиска текста: глиф → символ.
+func toUnicode(used map[sfnt.GlyphIndex]rune) string {
+	var s strings.Builder
+	s.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	glyphs := sortedGlyphs(used)
+	// в одном блоке bfchar не больше 100 записей
+	for len(glyphs) > 0 {
+		n := len(glyphs)
+		if n > 100 {
+			n = 100
+		}
+		fmt.Fprintf(&s, "%d beginbfchar\n", n)
+		for _, g := range glyphs[:n] {
+			fmt.Fprintf(&s, "<%04X> <%s\n", g, utf16Hex(string(used[g]))[5:])
+		}
+		s.WriteString("endbfchar\n")
+		glyphs = glyphs[n:]
+	}
+	s.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return s.String()
+}
+
+func sortedGlyphs(used map[sfnt.GlyphIndex]rune) []sfnt.GlyphIndex {
+	glyphs := make([]sfnt.GlyphIndex, 0, len(used))
+	for g := range used {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// subsetTag — префикс имени подмножества шрифта (ABCDEF+), зависит от набора глифов.
+func subsetTag(used map[sfnt.GlyphIndex]rune) string {
+	var h uint32 = 2166136261
+	for _, g := range sortedGlyphs(used) {
+		h = (h ^ uint32(g)) * 16777619
+	}
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(h%26)
+		h /= 26
+	}
+	return string(tag)
+}
+
+// Таблицы, которые остаются в подмножестве. post урезается до версии 3 (без имён глифов).
+var subsetTables = []string{"OS/2", "cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "name", "post", "prep"}
+
+// subset оставляет в шрифте только контуры использованных глифов (и составляющих
+// их частей); номера глифов не меняются, поэтому CIDToGIDMap остаётся Identity.
+func (f *ttf) subset(used map[sfnt.GlyphIndex]rune) ([]byte, error) {
+	tables, err := readTables(f.data)
+	if err != nil {
+		return nil, err
+	}
+	head, loca, glyf := tables["head"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || loca == nil || glyf == nil {
+		return nil, fmt.Errorf("pdf: font has no glyf outlines")
+	}
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	offsets := func(g int) (uint32, uint32, bool) {
+		if longLoca {
+			if 4*g+8 > len(loca) {
+				return 0, 0, false
+			}
+			return binary.BigEndian.Uint32(loca[4*g:]), binary.BigEndian.Uint32(loca[4*g+4:]), true
+		}
+		if 2*g+4 > len(loca) {
+			return 0, 0, false
+		}
+		return 2 * uint32(binary.BigEndian.Uint16(loca[2*g:])), 2 * uint32(binary.BigEndian.Uint16(loca[2*g+2:])), true
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+
+	keep := map[int]bool{0: true}
+	queue := []int{0}
+	for g := range used {
+		queue = append(queue, int(g))
+	}
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+		keep[g] = true
+		start, end, ok := offsets(g)
+		if !ok || end <= start || int(end) > len(glyf) {
+			continue
+		}
+		for _, c := range components(glyf[start:end]) {
+			if !keep[c] {
+				keep[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	var newGlyf bytes.Buffer
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for g := 0; g < numGlyphs; g++ {
+		binary.BigEndian.PutUint32(newLoca[4*g:], uint32(newGlyf.Len()))
+		if !keep[g] {
+			continue
+		}
+		if start, end, ok := offsets(g); ok && start < end && int(end) <= len(glyf) {
+			newGlyf.Write(glyf[start:end])
+			for newGlyf.Len()%4 != 0 {
+				newGlyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(newGlyf.Len()))
+
+	out := map[string][]byte{}
+	for _, tag := range subsetTables {
+		if t, ok := tables[tag]; ok {
+			out[tag] = t
+		}
+	}
+	out["glyf"], out["loca"] = newGlyf.Bytes(), newLoca
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0)  // checkSumAdjustment считается заново
+	binary.BigEndian.PutUint16(newHead[50:], 1) // loca в длинном формате
+	out["head"] = newHead
+	if post, ok := out["post"]; ok && len(post) >= 32 {
+		post = append([]byte(nil), post[:32]...)
+		binary.BigEndian.PutUint32(post, 0x00030000)
+		out["post"] = post
+	}
+	return writeTables(out), nil
+}
+
+// components — глифы, из которых собран составной глиф.
+func components(g []byte) []int {
+	if len(g) < 10 || int16(binary.BigEndian.Uint16(g)) >= 0 {
+		return nil
+	}
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	var out []int
+	for p := 10; p+4 <= len(g); {
+		flags := binary.BigEndian.Uint16(g[p:])
+		out = append(out, int(binary.BigEndian.Uint16(g[p+2:])))
+		p += 4
+		if flags&argsAreWords != 0 {
+			p += 4
+		} else {
+			p += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			p += 2
+		case flags&haveXYScale != 0:
+			p += 4
+		case flags&haveTwoByTwo != 0:
+			p += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return out
+}
+
+// readTables разбирает каталог таблиц TrueType.
+func readTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("pdf: bad font")
+	}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	tables := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("pdf: bad font table directory")
+		}
+		off := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if off < 0 || length < 0 || off+length > len(data) {
+			return nil, fmt.Errorf("pdf: bad font table %q", data[rec:rec+4])
+		}
+		tables[string(data[rec:rec+4])] = data[off : off+length]
+	}
+	return tables, nil
+}
+
+// writeTables собирает файл TrueType из таблиц с контрольными суммами.
+func writeTables(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	selector := 0
+	for 1<<(selector+1) <= n {
+		selector++
+	}
+	searchRange := (1 << selector) * 16
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint32{0x00010000})
+	binary.Write(&buf, binary.BigEndian, []uint16{uint16(n), uint16(searchRange), uint16(selector), uint16(n*16 - searchRange)})
+	offset := 12 + 16*n
+	for _, tag := range tags {
+		t := tables[tag]
+		buf.WriteString(tag)
+		binary.Write(&buf, binary.BigEndian, []uint32{checksum(t), uint32(offset), uint32(len(t))})
+		offset += (len(t) + 3) &^ 3
+	}
+	headAt := 0
+	for _, tag := range tags {
+		if tag == "head" {
+			headAt = buf.Len()
+		}
+		buf.Write(tables[tag])
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	out := buf.Bytes()
+	binary.BigEndian.PutUint32(out[headAt+8:], 0xB1B0AFBA-checksum(out))
+	return out
+}
+
+func checksum(b []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(b); i += 4 {
+		var word [4]byte
+		copy(word[:], b[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,234 @@
+// Package pdf — минимальная генерация PDF: страницы A4, заголовки и абзацы
+// с переносом строк. Шрифты Go Regular и Go Bold встраиваются подмножеством
+// (только использованные глифы), текст кодируется номерами глифов (Identity-H),
+// поэтому кириллица отображается в любом просмотрщике.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// Размеры A4 и поля в пунктах.
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+	margin     = 56.0
+)
+
+const (
+	textSize    = 11.0
+	headingSize = 15.0
+	leading     = 1.35
+)
+
+type font int
+
+const (
+	regular font = iota
+	bold
+)
+
+// Document — документ, который набирается сверху вниз; новая страница начинается сама.
+type Document struct {
+	title string
+	pages []*bytes.Buffer
+	y     float64
+	// used — глифы каждого шрифта, попавшие в документ, и их символы (для ToUnicode)
+	used [len(fonts)]map[sfnt.GlyphIndex]rune
+}
+
+func New(title string) *Document {
+	d := &Document{title: title}
+	for i := range d.used {
+		d.used[i] = map[sfnt.GlyphIndex]rune{}
+	}
+	d.newPage()
+	return d
+}
+
+func (d *Document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - margin
+}
+
+// Heading — жирный заголовок по центру.
+func (d *Document) Heading(text string) {
+	for _, line := range wrap(text, bold, headingSize) {
+		x := (pageWidth - textWidth(line, bold, headingSize)) / 2
+		d.line(line, bold, headingSize, x)
+	}
+	d.Space()
+}
+
+// Paragraph — абзац по левому краю с переносом по словам.
+func (d *Document) Paragraph(text string) {
+	for _, line := range wrap(text, regular, textSize) {
+		d.line(line, regular, textSize, margin)
+	}
+}
+
+// Space — пустая строка между абзацами.
+func (d *Document) Space() {
+	d.y -= textSize * leading
+}
+
+func (d *Document) line(text string, f font, size, x float64) {
+	if d.y-size < margin {
+		d.newPage()
+	}
+	d.y -= size
+	var hex strings.Builder
+	for _, r := range text {
+		g := fonts[f].glyph(r)
+		if _, ok := d.used[f][g]; !ok {
+			d.used[f][g] = r
+		}
+		fmt.Fprintf(&hex, "%04X", g)
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /F%d %.1f Tf %.2f %.2f Td <%s> Tj ET\n", f+1, size, x, d.y, hex.String())
+	d.y -= size * (leading - 1)
+}
+
+// WriteTo собирает файл: каталог, дерево страниц, сведения, шрифты, страницы с потоками и xref.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) int {
+		offsets = append(offsets, buf.Len())
+		n := len(offsets)
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", n, body)
+		return n
+	}
+	stream := func(dict string, data []byte) int {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(data)
+		zw.Close()
+		return obj(fmt.Sprintf("<< %s /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", dict, z.Len(), z.Bytes()))
+	}
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// номера объектов: 1 — каталог, 2 — страницы, 3 — сведения, дальше по пять
+	// на шрифт (Type0, CIDFont, FontDescriptor, FontFile2, ToUnicode), потом страницы
+	const fontObjects = 5
+	first := 4 + fontObjects*len(fonts)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", first+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj(fmt.Sprintf("<< /Title %s /Producer (example-app) /CreationDate (D:%s) >>",
+		utf16Hex(d.title), time.Now().UTC().Format("20060102150405Z")))
+	fontRefs := make([]string, len(fonts))
+	for i, f := range fonts {
+		n := 4 + fontObjects*i
+		used := d.used[i]
+		data, err := f.subset(used)
+		if err != nil {
+			return 0, err
+		}
+		name := subsetTag(used) + "+" + f.name
+		fontRefs[i] = fmt.Sprintf("/F%d %d 0 R", i+1, n)
+		obj(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			name, n+1, n+4))
+		obj(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+			"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW 1000 /W [%s] >>",
+			name, n+2, f.widths(used)))
+		obj(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%.0f %.0f %.0f %.0f] "+
+			"/ItalicAngle 0 /Ascent %.0f /Descent %.0f /CapHeight %.0f /StemV %d /FontFile2 %d 0 R >>",
+			name, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], f.ascent, f.descent, f.capHeight, 80+60*i, n+3))
+		stream(fmt.Sprintf("/Length1 %d", len(data)), data)
+		stream("", []byte(toUnicode(used)))
+	}
+	for i, page := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, strings.Join(fontRefs, " "), first+2*i+1))
+		stream("", page.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.WriteTo(w)
+}
+
+// Bytes — готовый файл целиком.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// wrap режет текст по словам так, чтобы строки помещались между полями;
+// слово шире строки (длинный адрес, email) режется посимвольно.
+func wrap(text string, f font, size float64) []string {
+	limit := pageWidth - 2*margin
+	var lines []string
+	var cur string
+	for _, word := range strings.Fields(text) {
+		next := word
+		if cur != "" {
+			next = cur + " " + word
+		}
+		if cur != "" && textWidth(next, f, size) > limit {
+			lines = append(lines, cur)
+			next = word
+		}
+		for textWidth(next, f, size) > limit {
+			head := fitPrefix(next, f, size, limit)
+			lines = append(lines, head)
+			next = next[len(head):]
+		}
+		cur = next
+	}
+	if cur != "" {
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+// fitPrefix — самое длинное начало строки (не меньше одного символа), которое помещается в limit.
+func fitPrefix(text string, f font, size, limit float64) string {
+	end := 0
+	for i, r := range text {
+		next := i + utf8.RuneLen(r)
+		if end > 0 && textWidth(text[:next], f, size) > limit {
+			break
+		}
+		end = next
+	}
+	return text[:end]
+}
+
+func textWidth(text string, f font, size float64) float64 {
+	var units float64
+	for _, r := range text {
+		units += fonts[f].advance(fonts[f].glyph(r))
+	}
+	return units * size / 1000
+}
+
+// utf16Hex — строка в UTF-16BE: сведения о документе и символы в ToUnicode.
+func utf16Hex(text string) string {
+	var s strings.Builder
+	s.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&s, "%04X", u)
+	}
+	s.WriteString(">")
+	return s.String()
+}
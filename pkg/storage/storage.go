@@ -0,0 +1,51 @@
+// Package storage — хранилище файлов (фото объектов и т. п.) с двумя реализациями:
+// локальный каталог и S3-совместимое хранилище (AWS S3, MinIO и другие).
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNotFound — файла с таким ключом нет.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage хранит файлы по ключам вида "properties/12/photos/ab12/thumb.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv выбирает хранилище по STORAGE: local (по умолчанию, каталог STORAGE_DIR)
+// или s3 (S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY).
+func FromEnv() (Storage, error) {
+	switch os.Getenv("STORAGE") {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocal(dir), nil
+	case "s3":
+		s := &S3{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required for STORAGE=s3")
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE: %s", os.Getenv("STORAGE"))
+	}
+}
@@ -2,7 +2,9 @@
 package store
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,7 +13,7 @@ import (
 	"github.com/go-chi/jwtauth"
 )
 
-func (s *StoreDB) Register(w http.ResponseWriter, r *http.Request) {
+func (s *Store) Register(w http.ResponseWriter, r *http.Request) {
 	regReq := RegisterRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&regReq); err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
@@ -23,22 +25,11 @@ func (s *StoreDB) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var email string
-	s.db.Get(&email, "SELECT email FROM users WHERE email = $1", regReq.Email)
-	if email != "" {
+	if _, err := s.users.UserByEmail(regReq.Email); err == nil {
 		http.Error(w, "Пользователь с этим email существует!", http.StatusConflict)
 		return
 	}
-	hashedPassword, err := HashPassword(regReq.Password)
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-	_, err = s.db.Exec(
-		"INSERT INTO users (username, email, password_hash, role_id) VALUES ($1, $2, $3, $4)",
-		regReq.UserName, regReq.Email, hashedPassword, 3,
-	)
-	if err != nil {
+	if _, err := s.users.CreateUser(regReq.UserName, regReq.Email, regReq.Password, RoleUser); err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -54,12 +45,12 @@ func (s *Store) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	user := User{}
-	s.storeDB.db.Get(
-		&user,
-		"SELECT id, username, email, password_hash, role_id FROM users WHERE email = $1",
-		loginReq.Email,
-	)
+	user, err := s.users.UserByEmail(loginReq.Email)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		log.Printf("Error loading user: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 	fmt.Println(user)
 	if user.Email == "" || !CheckPassword(user.Password, loginReq.Password) {
 		http.Error(w, "Неправильно указан email или пароль", http.StatusUnauthorized)
@@ -84,6 +75,77 @@ func (s *Store) Login(w http.ResponseWriter, r *http.Request) {
 		RoleID:      user.RoleID,
 	})
 }
+
+// CreateUser создаёт пользователя с указанной ролью и возвращает его id.
+func (s *StoreDB) CreateUser(name, email, password string, roleID int) (int, error) {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = s.db.Get(
+		&id,
+		"INSERT INTO users (username, email, password_hash, role_id) VALUES ($1, $2, $3, $4) RETURNING id",
+		name, email, hashedPassword, roleID,
+	)
+	return id, err
+}
+
+// ResetPassword задаёт новый пароль пользователю с указанным email.
+func (s *StoreDB) ResetPassword(email, password string) error {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(
+		"UPDATE users SET password_hash = $1, updated_at = now() WHERE email = $2",
+		hashedPassword, email,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found: %s", email)
+	}
+	return nil
+}
+
+// SetRole меняет роль пользователя с указанным email.
+func (s *StoreDB) SetRole(email string, roleID int) error {
+	result, err := s.db.Exec(
+		"UPDATE users SET role_id = $1, updated_at = now() WHERE email = $2",
+		roleID, email,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found: %s", email)
+	}
+	return nil
+}
+
+// UserByEmail возвращает пользователя по email; ErrUserNotFound, если его нет.
+func (s *StoreDB) UserByEmail(email string) (User, error) {
+	var user User
+	err := s.db.Get(
+		&user,
+		"SELECT id, username, email, password_hash, role_id FROM users WHERE email = $1",
+		email,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+// UserIDByEmail возвращает id пользователя по email.
+func (s *StoreDB) UserIDByEmail(email string) (int, error) {
+	var id int
+	err := s.db.Get(&id, "SELECT id FROM users WHERE email = $1", email)
+	return id, err
+}
+
 func GetIDUser(r *http.Request) (int, error) {
 	_, claims, _ := jwtauth.FromContext(r.Context())
 	id, ok := claims["user_id"].(float64)
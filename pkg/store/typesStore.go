@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"time"
 
 	"github.com/go-chi/jwtauth"
@@ -8,11 +9,28 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Роли пользователей (таблица roles).
+const (
+	RoleAdmin = 1
+	RoleAgent = 2
+	RoleUser  = 3
+)
+
+// ErrUserNotFound — пользователя с таким email нет.
+var ErrUserNotFound = errors.New("user not found")
+
+// Users — учётные записи для регистрации и входа. StoreDB хранит их в Postgres;
+// для запуска API без базы есть реализация в памяти (estate.MemoryUsers).
+type Users interface {
+	UserByEmail(email string) (User, error)
+	CreateUser(name, email, password string, roleID int) (int, error)
+}
+
 type StoreDB struct {
 	db *sqlx.DB
 }
 type Store struct {
-	storeDB   *StoreDB
+	users     Users
 	tokenAuth *jwtauth.JWTAuth
 }
 type User struct {
@@ -46,8 +64,8 @@ type ProfileResponse struct {
 func NewStoreDB(db *sqlx.DB) *StoreDB {
 	return &StoreDB{db: db}
 }
-func NewStore(storeDB *StoreDB, tokenAuth *jwtauth.JWTAuth) *Store {
-	return &Store{storeDB: storeDB, tokenAuth: tokenAuth}
+func NewStore(users Users, tokenAuth *jwtauth.JWTAuth) *Store {
+	return &Store{users: users, tokenAuth: tokenAuth}
 }
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
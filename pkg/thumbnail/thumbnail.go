@@ -0,0 +1,98 @@
+// Package thumbnail уменьшает изображения без внешних зависимостей: усреднение по
+// площади (box filter) даёт приличное качество при сильном уменьшении фотографий.
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+// Fit уменьшает img так, чтобы он помещался в квадрат max×max, сохраняя пропорции.
+// Меньшие изображения не увеличиваются. Прозрачность заливается белым.
+func Fit(img image.Image, max int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > max || h > max {
+		if w >= h {
+			tw, th = max, h*max/w
+		} else {
+			tw, th = w*max/h, max
+		}
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Over)
+	if tw == w && th == h {
+		return src
+	}
+	return resize(src, tw, th)
+}
+
+// resize — усреднение по площади: каждый пиксель результата — среднее покрываемого им
+// прямоугольника исходного изображения с учётом долей крайних пикселей.
+func resize(src *image.RGBA, tw, th int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	sx := float64(w) / float64(tw)
+	sy := float64(h) / float64(th)
+	for y := 0; y < th; y++ {
+		y0, y1 := float64(y)*sy, float64(y+1)*sy
+		for x := 0; x < tw; x++ {
+			x0, x1 := float64(x)*sx, float64(x+1)*sx
+			var r, g, bl, a, total float64
+			for iy := int(y0); iy < h && float64(iy) < y1; iy++ {
+				wy := overlap(float64(iy), y0, y1)
+				for ix := int(x0); ix < w && float64(ix) < x1; ix++ {
+					wgt := wy * overlap(float64(ix), x0, x1)
+					i := src.PixOffset(ix, iy)
+					r += wgt * float64(src.Pix[i])
+					g += wgt * float64(src.Pix[i+1])
+					bl += wgt * float64(src.Pix[i+2])
+					a += wgt * float64(src.Pix[i+3])
+					total += wgt
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r/total + 0.5)
+			dst.Pix[i+1] = uint8(g/total + 0.5)
+			dst.Pix[i+2] = uint8(bl/total + 0.5)
+			dst.Pix[i+3] = uint8(a/total + 0.5)
+		}
+	}
+	return dst
+}
+
+// overlap — длина пересечения пикселя [p, p+1) с отрезком [lo, hi).
+func overlap(p, lo, hi float64) float64 {
+	start, end := p, p+1
+	if lo > start {
+		start = lo
+	}
+	if hi < end {
+		end = hi
+	}
+	if end <= start {
+		return 0
+	}
+	return end - start
+}
+
+// JPEG кодирует изображение с качеством quality.
+func JPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,195 @@
+// Package xlsx — минимальное чтение и запись XLSX без внешних зависимостей:
+// только первый лист, только значения ячеек (без стилей и формул).
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type sharedStrings struct {
+	Items []struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type worksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline struct {
+				T string `xml:"t"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadRows читает все строки первого листа книги как строки.
+func ReadRows(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx file: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var strs []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var ss sharedStrings
+		if err := decodeXML(f, &ss); err != nil {
+			return nil, err
+		}
+		for _, it := range ss.Items {
+			if len(it.Runs) == 0 {
+				strs = append(strs, it.T)
+				continue
+			}
+			var b strings.Builder
+			for _, r := range it.Runs {
+				b.WriteString(r.T)
+			}
+			strs = append(strs, b.String())
+		}
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("xlsx: sheet %s not found", sheetPath)
+	}
+	var ws worksheet
+	if err := decodeXML(f, &ws); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(ws.Rows))
+	for _, row := range ws.Rows {
+		var out []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				if col, err = columnIndex(c.Ref); err != nil {
+					return nil, err
+				}
+			}
+			for len(out) < col {
+				out = append(out, "")
+			}
+			var v string
+			switch c.Type {
+			case "s":
+				idx, err := strconv.Atoi(c.Value)
+				if err != nil || idx < 0 || idx >= len(strs) {
+					return nil, fmt.Errorf("xlsx: bad shared string index in %s", c.Ref)
+				}
+				v = strs[idx]
+			case "inlineStr":
+				v = c.Inline.T
+			default:
+				v = c.Value
+			}
+			if col < len(out) {
+				out[col] = v
+			} else {
+				out = append(out, v)
+			}
+		}
+		rows = append(rows, out)
+	}
+	return rows, nil
+}
+
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	wbFile, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", fmt.Errorf("xlsx: workbook.xml not found")
+	}
+	var wb workbook
+	if err := decodeXML(wbFile, &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", fmt.Errorf("xlsx: workbook has no sheets")
+	}
+	// без связей пробуем стандартное имя первого листа
+	relFile, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok {
+		return "xl/worksheets/sheet1.xml", nil
+	}
+	var rels relationships
+	if err := decodeXML(relFile, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Items {
+		if rel.ID == wb.Sheets[0].RID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return "", fmt.Errorf("xlsx: first sheet relationship not found")
+}
+
+// maxColumns — колонок на листе Excel не больше XFD.
+const maxColumns = 16384
+
+// columnIndex переводит ссылку на ячейку ("C12") в номер колонки с нуля.
+// Колонки дальше XFD отклоняются: иначе одна ссылка вроде ZZZZZZ1 раздувает строку
+// на сотни миллионов пустых ячеек.
+func columnIndex(ref string) (int, error) {
+	n := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		n = n*26 + int(ref[i]-'A'+1)
+		if n > maxColumns {
+			return 0, fmt.Errorf("xlsx: cell reference %q is beyond column XFD", ref)
+		}
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("xlsx: bad cell reference %q", ref)
+	}
+	return n - 1, nil
+}
+
+func decodeXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, 100<<20)).Decode(v); err != nil {
+		return fmt.Errorf("xlsx: %s: %v", f.Name, err)
+	}
+	return nil
+}